@@ -1,8 +1,11 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 func buildBridgeViews(px *ProxmoxClient, cfg *Config) []BridgeView {
@@ -17,9 +20,29 @@ func buildBridgeViews(px *ProxmoxClient, cfg *Config) []BridgeView {
 		managed[b.Name] = true
 	}
 
+	isBridge := map[string]bool{}
+	for _, n := range networks {
+		if n.Type == "bridge" {
+			isBridge[n.Iface] = true
+		}
+	}
+
 	var bridges []BridgeView
 	for _, n := range networks {
-		if n.Type != "bridge" {
+		parent, vlan := "", 0
+		switch n.Type {
+		case "bridge", ovsBridgeType:
+		case "vlan":
+			// Only VLAN interfaces on a bridge gateway a VLAN network.
+			if parent, vlan = splitVLANNetwork(n.Iface); vlan == 0 || !isBridge[parent] {
+				continue
+			}
+		case ovsIntPortType:
+			if n.OVSBridge == "" {
+				continue
+			}
+			parent, vlan = n.OVSBridge, n.Tag()
+		default:
 			continue
 		}
 		cidr := n.CIDR
@@ -29,17 +52,111 @@ func buildBridgeViews(px *ProxmoxClient, cfg *Config) []BridgeView {
 			}
 		}
 		bridges = append(bridges, BridgeView{
-			Name:    n.Iface,
-			CIDR:    cidr,
-			Ports:   n.BridgePorts,
-			Managed: managed[n.Iface],
-			HasCIDR: cidr != "",
-			Address: n.Address,
-			Netmask: n.Netmask,
+			Name:      n.Iface,
+			CIDR:      cidr,
+			Type:      n.Type,
+			Ports:     n.Ports(),
+			VLAN:      vlan,
+			Parent:    parent,
+			VLANAware: n.VLANAware == 1,
+			Managed:   managed[n.Iface],
+			HasCIDR:   cidr != "",
+			Address:   n.Address,
+			Netmask:   n.Netmask,
 		})
 	}
 
+	bridges = append(bridges, listVNetViews(px, managed)...)
+
 	sort.Slice(bridges, func(i, j int) bool { return bridges[i].Name < bridges[j].Name })
 	return bridges
 }
 
+// bridgeGuests describes the guest NICs attached to bridge, tagged or not,
+// or to the VLAN network bridge names, e.g. "VM 101 (web) net0".
+func bridgeGuests(vms []VMView, bridge string) []string {
+	var out []string
+	for _, vm := range vms {
+		kind := "VM"
+		if vm.Type == "lxc" {
+			kind = "CT"
+		}
+		for _, nic := range vm.NICs {
+			if nic.Bridge != bridge && nic.Network != bridge {
+				continue
+			}
+			who := fmt.Sprintf("%s %d %s", kind, vm.VMID, nic.Key)
+			if vm.Name != "" {
+				who = fmt.Sprintf("%s %d (%s) %s", kind, vm.VMID, vm.Name, nic.Key)
+			}
+			out = append(out, who)
+		}
+	}
+	return out
+}
+
+// deleteBridge stages the removal of the bridge, VLAN interface or OVS
+// internal port name from this node; it takes effect when the pending network
+// changes are applied. It refuses while any guest in the cluster still has a
+// NIC on it or cannot be read, and never touches the WAN interface or SDN
+// VNets.
+func deleteBridge(px *ProxmoxClient, wan, name string) error {
+	if name == wan {
+		return fmt.Errorf("%s is the WAN interface", name)
+	}
+	networks, err := px.ListNetworks()
+	if err != nil {
+		return fmt.Errorf("list networks: %w", err)
+	}
+	found := false
+	for _, n := range networks {
+		if n.Iface == name && isNetworkType(n.Type) {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("no bridge, VLAN interface or OVS internal port %s on this node", name)
+	}
+
+	// Guests may have been attached since the inventory was cached.
+	px.Invalidate()
+	vms, err := px.ListVMs()
+	if err != nil {
+		return fmt.Errorf("list guests: %w", err)
+	}
+	views := buildVMViews(px, vms, nil)
+	var unread []string
+	for _, vm := range views {
+		if vm.Incomplete {
+			unread = append(unread, strconv.Itoa(vm.VMID))
+		}
+	}
+	if len(unread) > 0 {
+		return fmt.Errorf("cannot tell whether %s is in use: guest %s could not be read", name, strings.Join(unread, ", "))
+	}
+	if guests := bridgeGuests(views, name); len(guests) > 0 {
+		return fmt.Errorf("bridge %s is still used by %s", name, strings.Join(guests, ", "))
+	}
+
+	if err := px.DeleteNetworkOn(px.node, name); err != nil {
+		return fmt.Errorf("delete %s: %w", name, err)
+	}
+	return nil
+}
+
+// validateBridgeSettings checks edited bridge settings. cidr is checked by
+// the caller, which knows the other subnets.
+func validateBridgeSettings(s BridgeSettings) error {
+	for _, port := range strings.Fields(s.Ports) {
+		if !ifaceNameRe.MatchString(port) {
+			return fmt.Errorf("invalid bridge port %q", port)
+		}
+	}
+	if s.MTU != 0 && (s.MTU < 576 || s.MTU > 65520) {
+		return fmt.Errorf("MTU must be between 576 and 65520")
+	}
+	if strings.ContainsAny(s.Comments, "\r\n") {
+		return fmt.Errorf("comments must be a single line")
+	}
+	return nil
+}
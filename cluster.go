@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ForwardWarning flags a forward whose target guest runs on another cluster
+// node, so the DNAT rule points at an address nothing answers on here.
+type ForwardWarning struct {
+	Bridge    string `json:"bridge"`
+	ForwardID string `json:"forward_id"`
+	IntIP     string `json:"int_ip"`
+	VMID      int    `json:"vmid"`
+	VMName    string `json:"vm_name,omitempty"`
+	Node      string `json:"node"`
+}
+
+// migratedForwards returns a warning for every forward whose target address
+// belongs to a guest on another node. Targets are matched by guest for
+// forwards to a guest NIC, else by reservation MAC, lease or static IP. The
+// caller must hold the config lock.
+func migratedForwards(cfg *Config, vms []VMView) []ForwardWarning {
+	byIP := map[string]*VMView{}
+	byMAC := map[string]*VMView{}
+	byVMID := map[int]*VMView{}
+	for i := range vms {
+		vm := &vms[i]
+		byVMID[vm.VMID] = vm
+		for _, nic := range vm.NICs {
+			if nic.MAC != "" {
+				byMAC[normalizeMAC(nic.MAC)] = vm
+			}
+			if nic.LeaseIP != "" {
+				byIP[nic.LeaseIP] = vm
+			}
+			for _, ip := range append(nic.IPs, nic.AgentIPs...) {
+				byIP[strings.Split(ip, "/")[0]] = vm
+			}
+		}
+	}
+
+	var out []ForwardWarning
+	for _, b := range cfg.Bridges {
+		reserved := map[string]*VMView{}
+		if b.DHCP != nil {
+			for _, h := range b.DHCP.Hosts {
+				if vm := byMAC[normalizeMAC(h.MAC)]; vm != nil {
+					reserved[h.IP] = vm
+				}
+			}
+		}
+		for _, f := range b.Forwards {
+			var vm *VMView
+			switch {
+			case f.VMID != 0:
+				vm = byVMID[f.VMID]
+			case reserved[f.IntIP] != nil:
+				vm = reserved[f.IntIP]
+			default:
+				vm = byIP[f.IntIP]
+			}
+			if vm == nil || vm.Local {
+				continue
+			}
+			out = append(out, ForwardWarning{
+				Bridge:    b.Name,
+				ForwardID: f.ID,
+				IntIP:     f.IntIP,
+				VMID:      vm.VMID,
+				VMName:    vm.Name,
+				Node:      vm.Node,
+			})
+		}
+	}
+	return out
+}
+
+// NodeBridges reports which managed bridges are defined on a bridge node.
+type NodeBridges struct {
+	Node    string   `json:"node"`
+	Present []string `json:"present,omitempty"`
+	Missing []string `json:"missing,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// clusterBridges checks every node in bridge_nodes for the bridges the guests
+// of the managed networks are on.
+func clusterBridges(px *ProxmoxClient, nodes, bridges []string) []NodeBridges {
+	if len(nodes) == 0 {
+		return nil
+	}
+	local, err := px.ListNetworks()
+	if err != nil {
+		log.Printf("WARN: failed to list networks: %v", err)
+	}
+	bridges = guestBridges(local, bridges)
+	var out []NodeBridges
+	for _, node := range nodes {
+		nb := NodeBridges{Node: node}
+		networks, err := px.ListNetworksOn(node)
+		if err != nil {
+			nb.Error = err.Error()
+			out = append(out, nb)
+			continue
+		}
+		have := map[string]bool{}
+		for _, n := range networks {
+			if isBridgeType(n.Type) {
+				have[n.Iface] = true
+			}
+		}
+		for _, b := range bridges {
+			if have[b] {
+				nb.Present = append(nb.Present, b)
+			} else {
+				nb.Missing = append(nb.Missing, b)
+			}
+		}
+		out = append(out, nb)
+	}
+	return out
+}
+
+// syncBridgeNodes creates the missing bridges on every bridge node, without
+// address or ports: guests attached to them can migrate there, while NAT,
+// DHCP and forwards stay on this node. A VLAN network or OVS internal port
+// stands for its bridge, and OVS bridges are copied as OVS bridges.
+func syncBridgeNodes(px *ProxmoxClient, nodes, bridges []string) error {
+	local, err := px.ListNetworks()
+	if err != nil {
+		return fmt.Errorf("list networks: %w", err)
+	}
+	ovs := map[string]bool{}
+	for _, n := range local {
+		if n.Type == ovsBridgeType {
+			ovs[n.Iface] = true
+		}
+	}
+	var errs []error
+	for _, nb := range clusterBridges(px, nodes, bridges) {
+		if nb.Error != "" {
+			errs = append(errs, fmt.Errorf("node %s: %s", nb.Node, nb.Error))
+			continue
+		}
+		if len(nb.Missing) == 0 {
+			continue
+		}
+		created := 0
+		for _, b := range nb.Missing {
+			create := px.CreateBridgeOn
+			if ovs[b] {
+				create = px.CreateOVSBridgeOn
+			}
+			if err := create(nb.Node, b, "", ""); err != nil {
+				errs = append(errs, fmt.Errorf("node %s: create %s: %w", nb.Node, b, err))
+				continue
+			}
+			created++
+		}
+		if created > 0 {
+			if err := px.ReloadNetworkOn(nb.Node); err != nil {
+				errs = append(errs, fmt.Errorf("node %s: reload: %w", nb.Node, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// syncClusterBridges copies the bridges add to the bridge nodes and deletes
+// the copies of remove there.
+func syncClusterBridges(px *ProxmoxClient, nodes, add, remove []string) error {
+	var errs []error
+	if len(add) > 0 {
+		errs = append(errs, syncBridgeNodes(px, nodes, add))
+	}
+	for _, b := range remove {
+		errs = append(errs, removeBridgeNodes(px, nodes, b))
+	}
+	return errors.Join(errs...)
+}
+
+// checkBridgeCopies refuses when a copy of bridge on a bridge node has an
+// address, which PNAT copies never have.
+func checkBridgeCopies(px *ProxmoxClient, nodes []string, bridge string) error {
+	var addressed []string
+	for _, node := range nodes {
+		networks, err := px.ListNetworksOn(node)
+		if err != nil {
+			return fmt.Errorf("node %s: %w", node, err)
+		}
+		for _, n := range networks {
+			if n.Iface == bridge && n.CIDR != "" {
+				addressed = append(addressed, fmt.Sprintf("%s (%s)", node, n.CIDR))
+			}
+		}
+	}
+	if len(addressed) > 0 {
+		return fmt.Errorf("the copies of %s on %s have an address; remove it there first", bridge, strings.Join(addressed, ", "))
+	}
+	return nil
+}
+
+// removeBridgeNodes deletes the copies of bridge from the bridge nodes. A VLAN
+// network or OVS internal port has no copies; its bridge stays.
+func removeBridgeNodes(px *ProxmoxClient, nodes []string, bridge string) error {
+	if _, vlan := splitVLANNetwork(bridge); vlan != 0 {
+		return nil
+	}
+	local, err := px.ListNetworks()
+	if err != nil {
+		return fmt.Errorf("list networks: %w", err)
+	}
+	for _, n := range local {
+		if n.Iface == bridge && n.Type == ovsIntPortType {
+			return nil
+		}
+	}
+	var errs []error
+	for _, nb := range clusterBridges(px, nodes, []string{bridge}) {
+		if nb.Error != "" {
+			errs = append(errs, fmt.Errorf("node %s: %s", nb.Node, nb.Error))
+			continue
+		}
+		if len(nb.Present) == 0 {
+			continue
+		}
+		if err := px.DeleteNetworkOn(nb.Node, bridge); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: delete %s: %w", nb.Node, bridge, err))
+			continue
+		}
+		if err := px.ReloadNetworkOn(nb.Node); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: reload: %w", nb.Node, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// bridgeNames returns the names of the managed networks on bridges of this
+// node; SDN VNets are defined cluster-wide already. The caller must hold the
+// config lock.
+func (c *Config) bridgeNames() []string {
+	names := make([]string, 0, len(c.Bridges))
+	for _, b := range c.Bridges {
+		if b.SDNZone == "" {
+			names = append(names, b.Name)
+		}
+	}
+	return names
+}
+
+// guestBridges replaces VLAN networks and OVS internal ports by the bridge
+// their guests are on, once each.
+func guestBridges(networks []ProxmoxNetwork, names []string) []string {
+	out := make([]string, 0, len(names))
+	seen := map[string]bool{}
+	for _, name := range names {
+		name, _ = guestNetwork(networks, name)
+		if !seen[name] {
+			seen[name] = true
+			out = append(out, name)
+		}
+	}
+	return out
+}
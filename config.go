@@ -5,27 +5,35 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 // Config is the top-level application configuration, persisted as JSON.
 type Config struct {
-	ListenAddr     string         `json:"listen_addr"`
-	AuthMode       string         `json:"auth_mode,omitempty"`        // "local" or "pam"
-	AuthPamService string         `json:"auth_pam_service,omitempty"` // PAM service name, e.g. "pnat" or "login"
-	AuthAllowUsers []string       `json:"auth_allow_users,omitempty"` // optional allowlist for PAM auth
-	AdminUser      string         `json:"admin_user,omitempty"`       // for local auth
-	AdminPassHash  string         `json:"admin_pass_hash,omitempty"`  // for local auth (bcrypt)
-	SessionSecret  string         `json:"session_secret"`
-	ProxmoxURL     string         `json:"proxmox_url"`
-	ProxmoxTokenID string         `json:"proxmox_token_id"`
-	ProxmoxSecret  string         `json:"proxmox_secret"`
-	ProxmoxNode    string         `json:"proxmox_node"`
-	WanInterface   string         `json:"wan_interface"`
-	Bridges        []BridgeConfig `json:"bridges"`
+	ListenAddr         string         `json:"listen_addr"`
+	AuthMode           string         `json:"auth_mode,omitempty"`        // "local" or "pam"
+	AuthPamService     string         `json:"auth_pam_service,omitempty"` // PAM service name, e.g. "pnat" or "login"
+	AuthAllowUsers     []string       `json:"auth_allow_users,omitempty"` // optional allowlist for PAM auth
+	AdminUser          string         `json:"admin_user,omitempty"`       // for local auth
+	AdminPassHash      string         `json:"admin_pass_hash,omitempty"`  // for local auth (bcrypt)
+	SessionSecret      string         `json:"session_secret"`
+	ProxmoxURL         string         `json:"proxmox_url"`
+	ProxmoxTokenID     string         `json:"proxmox_token_id"`
+	ProxmoxSecret      string         `json:"proxmox_secret"`
+	ProxmoxNode        string         `json:"proxmox_node"`
+	ProxmoxCAFile      string         `json:"proxmox_ca_file,omitempty"`     // PEM bundle to verify the API certificate
+	ProxmoxFingerprint string         `json:"proxmox_fingerprint,omitempty"` // pinned SHA-256 of the API certificate
+	ProxmoxInsecure    bool           `json:"proxmox_insecure,omitempty"`    // skip certificate verification (explicit opt-in)
+	BridgeNodes        []string       `json:"bridge_nodes,omitempty"`        // other cluster nodes that get a copy of each managed bridge
+	WanInterface       string         `json:"wan_interface"`
+	SubnetPool         *SubnetPool    `json:"subnet_pool,omitempty"`   // new bridge subnets; defaultSubnetPool if unset
+	InventoryTTL       *int           `json:"inventory_ttl,omitempty"` // seconds the web server reuses Proxmox reads; default 15, 0 disables the cache
+	Bridges            []BridgeConfig `json:"bridges"`
 
 	mu   sync.Mutex `json:"-"`
 	path string     `json:"-"`
@@ -120,6 +128,202 @@ func (c *Config) DeleteBridge(name string) bool {
 	return false
 }
 
+// SetDHCPHost validates a reservation and adds it to the bridge, replacing any
+// existing one for the same MAC. The bridge is left untouched on error.
+func (b *BridgeConfig) SetDHCPHost(h DHCPHost) error {
+	return b.ReplaceDHCPHost(h.MAC, h)
+}
+
+// ReplaceDHCPHost validates a reservation and puts it in place of the one for
+// oldMAC, so that editing a reservation can change its MAC. Without a
+// reservation for oldMAC, h is added or replaces the one for its own MAC. The
+// bridge is left untouched on error.
+func (b *BridgeConfig) ReplaceDHCPHost(oldMAC string, h DHCPHost) error {
+	if b.DHCP == nil {
+		return fmt.Errorf("DHCP is not enabled on %s", b.Name)
+	}
+	oldMAC = normalizeMAC(oldMAC)
+	h.MAC = normalizeMAC(h.MAC)
+	if err := validateDHCPHost(b.Subnet, b.GatewayIP, h); err != nil {
+		return err
+	}
+	if oldMAC != h.MAC && b.hasDHCPHost(oldMAC) && b.hasDHCPHost(h.MAC) {
+		return fmt.Errorf("a reservation for %s already exists", h.MAC)
+	}
+	hosts := make([]DHCPHost, 0, len(b.DHCP.Hosts)+1)
+	replaced := false
+	for _, cur := range b.DHCP.Hosts {
+		if mac := normalizeMAC(cur.MAC); mac == oldMAC || mac == h.MAC {
+			if !replaced {
+				hosts = append(hosts, h)
+				replaced = true
+			}
+			continue
+		}
+		hosts = append(hosts, cur)
+	}
+	if !replaced {
+		hosts = append(hosts, h)
+	}
+	if err := validateDHCPHosts(b.Subnet, b.GatewayIP, hosts); err != nil {
+		return err
+	}
+	// A reservation inside a dynamic range keeps its address out of the pool.
+	d := *b.DHCP
+	d.Hosts = hosts
+	d.syncReservationExclusions(h.MAC)
+	if oldMAC != h.MAC {
+		d.syncReservationExclusions(oldMAC)
+	}
+	*b.DHCP = d
+	return nil
+}
+
+// hasDHCPHost reports whether the bridge has a reservation for mac.
+func (b *BridgeConfig) hasDHCPHost(mac string) bool {
+	for _, h := range b.DHCP.Hosts {
+		if mac != "" && normalizeMAC(h.MAC) == mac {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteDHCPHost removes a reservation by MAC. Returns true if found.
+func (b *BridgeConfig) DeleteDHCPHost(mac string) bool {
+	if b.DHCP == nil {
+		return false
+	}
+	mac = normalizeMAC(mac)
+	for i := range b.DHCP.Hosts {
+		if normalizeMAC(b.DHCP.Hosts[i].MAC) == mac {
+			b.DHCP.Hosts = append(b.DHCP.Hosts[:i], b.DHCP.Hosts[i+1:]...)
+			b.DHCP.syncReservationExclusions(mac)
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteDHCPOption removes an extra DHCP option by ID. Returns true if found.
+func (b *BridgeConfig) DeleteDHCPOption(id string) bool {
+	if b.DHCP == nil {
+		return false
+	}
+	for i := range b.DHCP.Options {
+		if b.DHCP.Options[i].ID == id {
+			b.DHCP.Options = append(b.DHCP.Options[:i], b.DHCP.Options[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// BridgeForIP returns the managed bridge whose subnet (or IPv6 prefix) contains ip, or nil.
+func (c *Config) BridgeForIP(ip string) *BridgeConfig {
+	ip4, err := parseIPv4(ip)
+	if err != nil {
+		return c.bridgeForIPv6(ip)
+	}
+	for i := range c.Bridges {
+		ipnet, err := parseCIDRv4(c.Bridges[i].Subnet)
+		if err != nil {
+			continue
+		}
+		if ipnet.Contains(ip4) {
+			return &c.Bridges[i]
+		}
+	}
+	return nil
+}
+
+func (c *Config) bridgeForIPv6(ip string) *BridgeConfig {
+	ip6, err := parseIPv6(ip)
+	if err != nil {
+		return nil
+	}
+	for i := range c.Bridges {
+		if c.Bridges[i].IPv6 == nil {
+			continue
+		}
+		prefix, err := parseCIDRv6(c.Bridges[i].IPv6.Prefix)
+		if err != nil {
+			continue
+		}
+		if prefix.Contains(ip6) {
+			return &c.Bridges[i]
+		}
+	}
+	return nil
+}
+
+// ReserveAddress turns mac/ip into a static reservation on the bridge that owns ip.
+func (c *Config) ReserveAddress(mac, ip, hostname string) (*BridgeConfig, error) {
+	if _, err := parseIPv4(ip); err != nil {
+		return nil, fmt.Errorf("only IPv4 leases can be reserved")
+	}
+	br := c.BridgeForIP(ip)
+	if br == nil {
+		return nil, fmt.Errorf("IP %s is not on a managed bridge", ip)
+	}
+	if !hostnameRe.MatchString(hostname) {
+		hostname = ""
+	}
+	if err := br.SetDHCPHost(DHCPHost{MAC: mac, IP: ip, Hostname: hostname}); err != nil {
+		return nil, err
+	}
+	return br, nil
+}
+
+// checkSubnet reports an overlap of subnet with a bridge other than name or
+// with a network on the WAN interface.
+func (c *Config) checkSubnet(name, subnet string) error {
+	ipnet, err := parseCIDRv4(subnet)
+	if err != nil {
+		return fmt.Errorf("invalid subnet %q", subnet)
+	}
+	for _, b := range c.Bridges {
+		if b.Name == name {
+			continue
+		}
+		other, err := parseCIDRv4(b.Subnet)
+		if err != nil {
+			continue
+		}
+		if netsOverlap(ipnet, other) {
+			return fmt.Errorf("subnet %s overlaps bridge %s (%s)", subnet, b.Name, b.Subnet)
+		}
+	}
+	for _, wan := range wanNetworks(c.WanInterface) {
+		if netsOverlap(ipnet, wan) {
+			return fmt.Errorf("subnet %s overlaps WAN network %s on %s", subnet, wan, c.WanInterface)
+		}
+	}
+	return nil
+}
+
+// NextFreeSubnet suggests the first subnet of the subnet pool that overlaps
+// no bridge, WAN network or any of extra (CIDRs in use outside PNAT).
+func (c *Config) NextFreeSubnet(extra []string) (SubnetSuggestion, error) {
+	pool := defaultSubnetPool
+	if c.SubnetPool != nil {
+		pool = *c.SubnetPool
+	}
+	var taken []*net.IPNet
+	for _, s := range extra {
+		if n, err := parseCIDRv4(s); err == nil {
+			taken = append(taken, n)
+		}
+	}
+	for _, b := range c.Bridges {
+		if n, err := parseCIDRv4(b.Subnet); err == nil {
+			taken = append(taken, n)
+		}
+	}
+	taken = append(taken, wanNetworks(c.WanInterface)...)
+	return nextFreeSubnet(pool, taken)
+}
+
 func (c *Config) validate() error {
 	if c.ListenAddr == "" {
 		c.ListenAddr = "127.0.0.1:9090"
@@ -153,6 +357,29 @@ func (c *Config) validate() error {
 	if c.WanInterface == "" {
 		return fmt.Errorf("wan_interface is required")
 	}
+	if c.ProxmoxFingerprint != "" {
+		fp, err := normalizeFingerprint(c.ProxmoxFingerprint)
+		if err != nil {
+			return err
+		}
+		c.ProxmoxFingerprint = fp
+	}
+	if c.ProxmoxInsecure && (c.ProxmoxFingerprint != "" || c.ProxmoxCAFile != "") {
+		return fmt.Errorf("proxmox_insecure cannot be combined with proxmox_fingerprint or proxmox_ca_file")
+	}
+	if c.InventoryTTL != nil && *c.InventoryTTL < 0 {
+		return fmt.Errorf("inventory_ttl must not be negative")
+	}
+	for _, n := range c.BridgeNodes {
+		if n == "" || n == c.ProxmoxNode {
+			return fmt.Errorf("bridge_nodes: invalid node %q", n)
+		}
+	}
+	if c.SubnetPool != nil {
+		if err := validateSubnetPool(c.SubnetPool); err != nil {
+			return err
+		}
+	}
 	for _, b := range c.Bridges {
 		if b.Name == "" {
 			return fmt.Errorf("bridge name is required")
@@ -163,14 +390,64 @@ func (c *Config) validate() error {
 		if net.ParseIP(b.GatewayIP) == nil {
 			return fmt.Errorf("bridge %s: invalid gateway_ip %q", b.Name, b.GatewayIP)
 		}
+		// Overlaps are refused when a bridge is created or edited; a saved
+		// config that has one, e.g. after the WAN network changed, still
+		// loads.
+		if err := c.checkSubnet(b.Name, b.Subnet); err != nil {
+			log.Printf("WARN: bridge %s: %v", b.Name, err)
+		}
+		if err := validateVLANNetwork(b); err != nil {
+			return fmt.Errorf("bridge %s: %w", b.Name, err)
+		}
 		for _, f := range b.Forwards {
 			if f.ExtPort == 0 || f.IntPort == 0 {
 				return fmt.Errorf("bridge %s: forward ports must be > 0", b.Name)
 			}
+			if f.VMID != 0 || f.NIC != "" {
+				if _, _, err := parseForwardTarget(fmt.Sprintf("%d:%s", f.VMID, f.NIC)); err != nil {
+					return fmt.Errorf("bridge %s: forward %s: %w", b.Name, f.ID, err)
+				}
+				// Unresolved targets have no int_ip.
+				if f.IntIP == "" {
+					continue
+				}
+			}
 			if net.ParseIP(f.IntIP) == nil {
 				return fmt.Errorf("bridge %s: invalid forward int_ip %q", b.Name, f.IntIP)
 			}
 		}
+		if b.DHCP != nil {
+			if err := validateDHCPHosts(b.Subnet, b.GatewayIP, b.DHCP.Hosts); err != nil {
+				return fmt.Errorf("bridge %s: %w", b.Name, err)
+			}
+			// Older configs allowed reservations inside the range; exclude them.
+			for _, h := range b.DHCP.Hosts {
+				if _, ok := poolContaining(b.DHCP.pools(), h.IP); ok {
+					b.DHCP.syncReservationExclusions(h.MAC)
+				}
+			}
+			if err := validateDHCPPools(b.Subnet, b.GatewayIP, b.DHCP, nil); err != nil {
+				return fmt.Errorf("bridge %s: %w", b.Name, err)
+			}
+			if err := validateDHCPOptions(b.DHCP.Options); err != nil {
+				return fmt.Errorf("bridge %s: %w", b.Name, err)
+			}
+			if b.DHCP.Boot != nil {
+				if err := validateDHCPBoot(b.DHCP.Boot, b.DHCP.Options); err != nil {
+					return fmt.Errorf("bridge %s: %w", b.Name, err)
+				}
+			}
+		}
+		if b.DNS != nil {
+			if err := validateDNSConfig(b.DNS); err != nil {
+				return fmt.Errorf("bridge %s: %w", b.Name, err)
+			}
+		}
+		if b.IPv6 != nil {
+			if err := validateIPv6Config(b.IPv6); err != nil {
+				return fmt.Errorf("bridge %s: %w", b.Name, err)
+			}
+		}
 	}
 	return nil
 }
@@ -188,3 +465,17 @@ func GenerateSessionSecret() (string, error) {
 func DefaultConfigPath() string {
 	return filepath.Join("/etc", "pnat", "pnat.json")
 }
+
+// inventoryTTL returns how long the web server reuses Proxmox reads; 0 turns
+// the cache off.
+func (c *Config) inventoryTTL() time.Duration {
+	if c.InventoryTTL == nil {
+		return defaultInventoryTTL
+	}
+	return time.Duration(*c.InventoryTTL) * time.Second
+}
+
+// proxmoxTLS returns the certificate verification settings for the API client.
+func (c *Config) proxmoxTLS() ProxmoxTLS {
+	return ProxmoxTLS{CAFile: c.ProxmoxCAFile, Fingerprint: c.ProxmoxFingerprint, Insecure: c.ProxmoxInsecure}
+}
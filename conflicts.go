@@ -0,0 +1,359 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// arpProbeWorkers limits how many arping processes run at once.
+const arpProbeWorkers = 16
+
+// IPConflict is an address on a managed bridge claimed by more than one client.
+type IPConflict struct {
+	Bridge string   `json:"bridge"`
+	IP     string   `json:"ip"`
+	Kind   string   `json:"kind"` // see conflictKinds
+	MACs   []string `json:"macs,omitempty"`
+	Detail string   `json:"detail"`
+}
+
+var conflictKinds = map[string]string{
+	"static-static":      "Duplicate static IP",
+	"static-reservation": "Static IP is reserved for another MAC",
+	"static-lease":       "Static IP leased to another MAC",
+	"static-in-range":    "Static IP inside DHCP range",
+	"lease-reservation":  "Reserved IP leased to another MAC",
+	"arp-duplicate":      "Several MACs answer ARP",
+	"unknown-mac":        "Unknown MAC answers for managed IP",
+}
+
+// KindLabel returns a human-readable name for the conflict kind.
+func (c IPConflict) KindLabel() string {
+	if l, ok := conflictKinds[c.Kind]; ok {
+		return l
+	}
+	return c.Kind
+}
+
+// ConflictDetector periodically correlates configured and observed addresses
+// on managed bridges and keeps the findings of the last scan.
+type ConflictDetector struct {
+	mu        sync.Mutex
+	conflicts []IPConflict
+	scanned   time.Time
+	arping    bool // last scan sent ARP probes
+}
+
+func NewConflictDetector() *ConflictDetector {
+	return &ConflictDetector{}
+}
+
+// Results returns the conflicts found by the last scan, when it ran and
+// whether it used ARP probes (arping installed).
+func (d *ConflictDetector) Results() ([]IPConflict, time.Time, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.conflicts, d.scanned, d.arping
+}
+
+// Scan checks cfg, leases and guest NICs for addresses claimed twice, then
+// asks the network who answers for every managed address: the neighbour
+// table always, and ARP probes when arping is installed.
+func (d *ConflictDetector) Scan(cfg *Config, leases []Lease, vms []VMView) {
+	cfg.Lock()
+	conflicts := findIPConflicts(cfg, leases, vms)
+	known := knownMACs(cfg, leases, vms)
+	targets := probeTargets(cfg, leases, vms)
+	cfg.Unlock()
+
+	_, err := exec.LookPath("arping")
+	arping := err == nil
+	neighbors := map[string]map[string][]string{}
+	for _, t := range targets {
+		if _, ok := neighbors[t.bridge]; !ok {
+			neighbors[t.bridge] = neighborMACs(t.bridge)
+		}
+		for _, mac := range neighbors[t.bridge][t.ip] {
+			t.addSeen(mac)
+		}
+	}
+	if arping {
+		var wg sync.WaitGroup
+		sem := make(chan struct{}, arpProbeWorkers)
+		for _, t := range targets {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(t *probeTarget) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				macs, err := arpProbe(t.bridge, t.ip)
+				if err != nil {
+					log.Printf("WARN: arping %s on %s: %v", t.ip, t.bridge, err)
+					return
+				}
+				for _, mac := range macs {
+					t.addSeen(mac)
+				}
+			}(t)
+		}
+		wg.Wait()
+	}
+	for _, t := range targets {
+		conflicts = append(conflicts, t.conflicts(known)...)
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		if conflicts[i].Bridge != conflicts[j].Bridge {
+			return conflicts[i].Bridge < conflicts[j].Bridge
+		}
+		return ipSortKey(conflicts[i].IP) < ipSortKey(conflicts[j].IP)
+	})
+
+	d.mu.Lock()
+	d.conflicts, d.scanned, d.arping = conflicts, time.Now(), arping
+	d.mu.Unlock()
+}
+
+func ipSortKey(ip string) uint32 {
+	if ip4, err := parseIPv4(ip); err == nil {
+		return ipToUint32(ip4)
+	}
+	return 0
+}
+
+// guestNICOwner describes a guest NIC, e.g. "VM 101 (web) net0".
+func guestNICOwner(vm VMView, nic VMNICView) string {
+	kind := "VM"
+	if vm.Type == "lxc" {
+		kind = "CT"
+	}
+	if vm.Name != "" {
+		return fmt.Sprintf("%s %d (%s) %s", kind, vm.VMID, vm.Name, nic.Key)
+	}
+	return fmt.Sprintf("%s %d %s", kind, vm.VMID, nic.Key)
+}
+
+// findIPConflicts reports conflicts visible in the configuration alone:
+// static guest IPs used twice, reserved or leased to other MACs, or inside a
+// DHCP pool, and reserved IPs leased to other MACs.
+func findIPConflicts(cfg *Config, leases []Lease, vms []VMView) []IPConflict {
+	leased := map[string]Lease{}
+	for _, l := range leases {
+		if !l.IsIPv6() {
+			leased[l.IP] = l
+		}
+	}
+	var out []IPConflict
+	for _, b := range cfg.Bridges {
+		type staticNIC struct{ mac, owner string }
+		statics := map[string][]staticNIC{}
+		var staticIPs []string
+		for _, vm := range vms {
+			for _, nic := range vm.NICs {
+				if nic.Network != b.Name || nic.StaticIP == "" {
+					continue
+				}
+				if _, ok := statics[nic.StaticIP]; !ok {
+					staticIPs = append(staticIPs, nic.StaticIP)
+				}
+				statics[nic.StaticIP] = append(statics[nic.StaticIP], staticNIC{normalizeMAC(nic.MAC), guestNICOwner(vm, nic)})
+			}
+		}
+		reserved := map[string]DHCPHost{}
+		var pools []ipRange
+		if b.DHCP != nil {
+			for _, h := range b.DHCP.Hosts {
+				reserved[h.IP] = h
+			}
+			pools = b.DHCP.pools()
+		}
+		for _, ip := range staticIPs {
+			nics := statics[ip]
+			if len(nics) > 1 {
+				owners := make([]string, 0, len(nics))
+				macs := make([]string, 0, len(nics))
+				for _, n := range nics {
+					owners = append(owners, n.owner)
+					macs = append(macs, n.mac)
+				}
+				out = append(out, IPConflict{Bridge: b.Name, IP: ip, Kind: "static-static", MACs: macs,
+					Detail: "configured on " + strings.Join(owners, ", ")})
+			}
+			first := nics[0]
+			if h, ok := reserved[ip]; ok && normalizeMAC(h.MAC) != first.mac {
+				out = append(out, IPConflict{Bridge: b.Name, IP: ip, Kind: "static-reservation", MACs: []string{first.mac, normalizeMAC(h.MAC)},
+					Detail: fmt.Sprintf("static on %s, reserved for %s", first.owner, normalizeMAC(h.MAC))})
+			}
+			if l, ok := leased[ip]; ok && normalizeMAC(l.MAC) != first.mac {
+				out = append(out, IPConflict{Bridge: b.Name, IP: ip, Kind: "static-lease", MACs: []string{first.mac, normalizeMAC(l.MAC)},
+					Detail: fmt.Sprintf("static on %s, leased to %s", first.owner, normalizeMAC(l.MAC))})
+			}
+			if p, ok := poolContaining(pools, ip); ok {
+				out = append(out, IPConflict{Bridge: b.Name, IP: ip, Kind: "static-in-range", MACs: []string{first.mac},
+					Detail: fmt.Sprintf("static on %s, inside DHCP range %s", first.owner, p)})
+			}
+		}
+		for ip, h := range reserved {
+			if l, ok := leased[ip]; ok && normalizeMAC(l.MAC) != normalizeMAC(h.MAC) {
+				out = append(out, IPConflict{Bridge: b.Name, IP: ip, Kind: "lease-reservation", MACs: []string{normalizeMAC(h.MAC), normalizeMAC(l.MAC)},
+					Detail: fmt.Sprintf("reserved for %s, leased to %s", normalizeMAC(h.MAC), normalizeMAC(l.MAC))})
+			}
+		}
+	}
+	return out
+}
+
+// knownMACs lists every MAC PNAT knows from leases, reservations and guest NICs.
+func knownMACs(cfg *Config, leases []Lease, vms []VMView) map[string]bool {
+	out := map[string]bool{}
+	for _, l := range leases {
+		out[normalizeMAC(l.MAC)] = true
+	}
+	for mac := range reservedMACs(cfg) {
+		out[mac] = true
+	}
+	for _, vm := range vms {
+		for _, nic := range vm.NICs {
+			if nic.MAC != "" {
+				out[normalizeMAC(nic.MAC)] = true
+			}
+		}
+	}
+	return out
+}
+
+// probeTarget is a managed address together with the MACs expected and seen for it.
+type probeTarget struct {
+	bridge   string
+	ip       string
+	gateway  bool
+	expected map[string]bool
+
+	mu   sync.Mutex
+	seen []string
+}
+
+func (t *probeTarget) addSeen(mac string) {
+	mac = normalizeMAC(mac)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range t.seen {
+		if m == mac {
+			return
+		}
+	}
+	t.seen = append(t.seen, mac)
+}
+
+// conflicts reports several MACs answering for t, or one that PNAT does not
+// know. Nobody else may answer for the gateway address.
+func (t *probeTarget) conflicts(known map[string]bool) []IPConflict {
+	var out []IPConflict
+	if len(t.seen) > 1 {
+		out = append(out, IPConflict{Bridge: t.bridge, IP: t.ip, Kind: "arp-duplicate", MACs: t.seen,
+			Detail: "answered by " + strings.Join(t.seen, ", ")})
+	}
+	for _, mac := range t.seen {
+		switch {
+		case t.gateway:
+			out = append(out, IPConflict{Bridge: t.bridge, IP: t.ip, Kind: "unknown-mac", MACs: []string{mac},
+				Detail: mac + " answers for the gateway address"})
+		case !known[mac] && !t.expected[mac]:
+			out = append(out, IPConflict{Bridge: t.bridge, IP: t.ip, Kind: "unknown-mac", MACs: []string{mac},
+				Detail: mac + " is not a known guest, lease or reservation"})
+		}
+	}
+	return out
+}
+
+// probeTargets lists the managed IPv4 addresses of every bridge: gateway,
+// forward targets, leases, reservations and guest IPs.
+func probeTargets(cfg *Config, leases []Lease, vms []VMView) []*probeTarget {
+	byKey := map[string]*probeTarget{}
+	var out []*probeTarget
+	add := func(bridge, ip, mac string) *probeTarget {
+		key := bridge + "|" + ip
+		t, ok := byKey[key]
+		if !ok {
+			t = &probeTarget{bridge: bridge, ip: ip, expected: map[string]bool{}}
+			byKey[key] = t
+			out = append(out, t)
+		}
+		if mac != "" {
+			t.expected[normalizeMAC(mac)] = true
+		}
+		return t
+	}
+	for _, b := range buildUsedIPs(cfg, leases, vms) {
+		for _, u := range b.IPs {
+			if _, err := parseIPv4(u.IP); err != nil {
+				continue
+			}
+			t := add(b.Bridge, u.IP, u.MAC)
+			if u.Source == "gateway" {
+				t.gateway = true
+			}
+		}
+	}
+	for _, b := range cfg.Bridges {
+		if b.DHCP == nil {
+			continue
+		}
+		for _, h := range b.DHCP.Hosts {
+			add(b.Name, h.IP, h.MAC)
+		}
+	}
+	return out
+}
+
+var macRe = regexp.MustCompile(`(?i)\b[0-9a-f]{2}(?::[0-9a-f]{2}){5}\b`)
+
+// arpProbe sends ARP requests for ip on iface and returns the MACs that
+// answered. Both iputils and Habets arping output is understood.
+func arpProbe(iface, ip string) ([]string, error) {
+	out, err := exec.Command("arping", "-c", "2", "-w", "2", "-I", iface, ip).CombinedOutput()
+	if err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil, err
+		}
+		// arping exits non-zero when nobody answered.
+	}
+	var macs []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.Contains(line, ip) || !strings.Contains(strings.ToLower(line), "from") {
+			continue
+		}
+		mac := normalizeMAC(macRe.FindString(line))
+		if mac == "" || seen[mac] {
+			continue
+		}
+		seen[mac] = true
+		macs = append(macs, mac)
+	}
+	return macs, nil
+}
+
+// neighborMACs reads the kernel neighbour table of iface (IP to MACs).
+func neighborMACs(iface string) map[string][]string {
+	out, err := exec.Command("ip", "-4", "neigh", "show", "dev", iface).Output()
+	if err != nil {
+		return nil
+	}
+	res := map[string][]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		for i := 1; i+1 < len(fields); i++ {
+			if fields[i] == "lladdr" {
+				res[fields[0]] = append(res[fields[0]], normalizeMAC(fields[i+1]))
+				break
+			}
+		}
+	}
+	return res
+}
@@ -6,48 +6,263 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
 	dnsmasqConfigPath = "/etc/pnat/dnsmasq.conf"
-	dnsmasqLeaseFile  = "/var/lib/pnat/dnsmasq.leases"
-	dnsmasqUnit       = "pnat-dnsmasq.service"
+	dnsmasqHostsFile  = "/etc/pnat/dnsmasq.hosts"
+
+	dnsmasqDHCPHostsFile = "/etc/pnat/dnsmasq.dhcp-hosts"
+	dnsmasqDHCPOptsFile  = "/etc/pnat/dnsmasq.dhcp-opts"
+	dnsmasqLeaseFile     = "/var/lib/pnat/dnsmasq.leases"
+	dnsmasqUnit          = "pnat-dnsmasq.service"
+
+	// uefiTag marks DHCP clients that report an x86-64 UEFI architecture.
+	uefiTag = "pnat-efi"
+)
+
+// dhcpOptionSpec describes a known DHCP option and how its value is checked.
+type dhcpOptionSpec struct {
+	Code  int
+	Label string
+	Kind  string // "ipv4-list", "domain", "domain-list", "routes", "uint16", "hex"
+}
+
+var dhcpOptionSpecs = map[string]dhcpOptionSpec{
+	"ntp-server":             {Code: 42, Label: "NTP servers", Kind: "ipv4-list"},
+	"domain-name":            {Code: 15, Label: "Domain name", Kind: "domain"},
+	"domain-search":          {Code: 119, Label: "Domain search list", Kind: "domain-list"},
+	"classless-static-route": {Code: 121, Label: "Classless static routes", Kind: "routes"},
+	"mtu":                    {Code: 26, Label: "Interface MTU", Kind: "uint16"},
+	"vendor-encap":           {Code: 43, Label: "Vendor-specific (hex)", Kind: "hex"},
+}
+
+// dhcpOptionNames returns the known option names in a stable order.
+func dhcpOptionNames() []string {
+	names := make([]string, 0, len(dhcpOptionSpecs))
+	for name := range dhcpOptionSpecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// OptionCode returns the numeric DHCP option code.
+func (o DHCPOption) OptionCode() int {
+	if spec, ok := dhcpOptionSpecs[o.Name]; ok {
+		return spec.Code
+	}
+	return o.Code
+}
+
+// Label returns a human-readable option name.
+func (o DHCPOption) Label() string {
+	if spec, ok := dhcpOptionSpecs[o.Name]; ok {
+		return spec.Label
+	}
+	return fmt.Sprintf("Option %d", o.Code)
+}
+
+// IsIPv6 reports whether the lease is a DHCPv6 lease.
+func (l Lease) IsIPv6() bool {
+	return strings.Contains(l.IP, ":")
+}
+
+// ExpiresIn returns the time left on the lease; infinite leases report ok=false.
+func (l Lease) ExpiresIn() (time.Duration, bool) {
+	if l.Expires.IsZero() {
+		return 0, false
+	}
+	return time.Until(l.Expires), true
+}
+
+// ExpiresInText formats the remaining lease time for display.
+func (l Lease) ExpiresInText() string {
+	left, ok := l.ExpiresIn()
+	switch {
+	case !ok:
+		return "infinite"
+	case left <= 0:
+		return "expired"
+	}
+	return left.Truncate(time.Second).String()
+}
+
+// ipv6Modes lists the IPv6 address modes in display order.
+var ipv6Modes = []string{"slaac", "stateless", "stateful"}
+
+var ipv6ModeLabels = map[string]string{
+	"slaac":     "SLAAC only",
+	"stateless": "SLAAC + stateless DHCPv6",
+	"stateful":  "Stateful DHCPv6",
+}
+
+// ModeLabel returns a human-readable name for the IPv6 address mode.
+func (v IPv6Config) ModeLabel() string {
+	if label, ok := ipv6ModeLabels[v.Mode]; ok {
+		return label
+	}
+	return v.Mode
+}
+
+// macFromDUID extracts the link-layer address from a DUID-LLT or DUID-LL with
+// an Ethernet hardware type, or returns "".
+func macFromDUID(duid string) string {
+	parts := strings.Split(duid, ":")
+	var mac []string
+	switch {
+	case len(parts) == 14 && parts[0] == "00" && parts[1] == "01" && parts[2] == "00" && parts[3] == "01":
+		mac = parts[8:]
+	case len(parts) == 10 && parts[0] == "00" && parts[1] == "03" && parts[2] == "00" && parts[3] == "01":
+		mac = parts[4:]
+	default:
+		return ""
+	}
+	return normalizeMAC(strings.Join(mac, ":"))
+}
+
+// DNSMasqAction says how Apply brought dnsmasq in line with the config.
+type DNSMasqAction string
+
+const (
+	DNSMasqUnchanged DNSMasqAction = "unchanged"
+	DNSMasqReloaded  DNSMasqAction = "reloaded"  // SIGHUP: hosts/options re-read, leases kept
+	DNSMasqRestarted DNSMasqAction = "restarted" // interfaces, ranges or other main config changed
+	DNSMasqStopped   DNSMasqAction = "stopped"
 )
 
-// DNSMasqManager manages dnsmasq configuration and service for DHCP.
-type DNSMasqManager struct{}
+// DNSMasqManager manages dnsmasq configuration and service for DHCP and DNS.
+type DNSMasqManager struct {
+	mu         sync.Mutex
+	lastAction DNSMasqAction
+	lastApply  time.Time
+}
 
 func NewDNSMasqManager() *DNSMasqManager {
 	return &DNSMasqManager{}
 }
 
-// Apply generates the dnsmasq config and restarts/stops the service as needed.
-func (d *DNSMasqManager) Apply(cfg *Config) error {
-	hasDHCP := false
+// Apply generates the dnsmasq config files and brings the service in line.
+// Reservations and options live in dhcp-hostsfile/dhcp-optsfile and are
+// picked up with SIGHUP; only changes to the main config (interfaces, ranges,
+// DNS, boot) restart the service.
+func (d *DNSMasqManager) Apply(cfg *Config) (DNSMasqAction, error) {
+	action, err := d.apply(cfg)
+	if err != nil {
+		return action, err
+	}
+	d.mu.Lock()
+	d.lastAction, d.lastApply = action, time.Now()
+	d.mu.Unlock()
+	log.Printf("dnsmasq config applied (%s)", action)
+	return action, nil
+}
+
+// LastApply returns the action taken by the most recent successful Apply.
+func (d *DNSMasqManager) LastApply() (DNSMasqAction, time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastAction, d.lastApply
+}
+
+func (d *DNSMasqManager) apply(cfg *Config) (DNSMasqAction, error) {
+	needed := false
 	for _, b := range cfg.Bridges {
-		if b.DHCP != nil {
-			hasDHCP = true
+		if b.DHCP != nil || b.DNS != nil || b.IPv6 != nil {
+			needed = true
 			break
 		}
 	}
 
-	if !hasDHCP {
-		return d.stop()
+	if !needed {
+		return DNSMasqStopped, d.stop()
+	}
+
+	if hasDNS(cfg) {
+		if _, err := os.Stat(dnsmasqHostsFile); os.IsNotExist(err) {
+			if err := os.WriteFile(dnsmasqHostsFile, nil, 0644); err != nil {
+				return "", fmt.Errorf("write dnsmasq hosts: %w", err)
+			}
+		}
 	}
 
-	config := d.generateConfig(cfg)
-	if err := os.WriteFile(dnsmasqConfigPath, []byte(config), 0644); err != nil {
-		return fmt.Errorf("write dnsmasq config: %w", err)
+	for _, b := range cfg.Bridges {
+		if b.DHCP != nil && b.DHCP.Boot != nil && b.DHCP.Boot.TFTPRoot != "" {
+			if err := os.MkdirAll(b.DHCP.Boot.TFTPRoot, 0755); err != nil {
+				return "", fmt.Errorf("create TFTP root: %w", err)
+			}
+		}
+	}
+
+	hostsChanged, err := writeFileIfChanged(dnsmasqDHCPHostsFile, d.generateDHCPHosts(cfg))
+	if err != nil {
+		return "", fmt.Errorf("write dnsmasq dhcp hosts: %w", err)
+	}
+	optsChanged, err := writeFileIfChanged(dnsmasqDHCPOptsFile, d.generateDHCPOpts(cfg))
+	if err != nil {
+		return "", fmt.Errorf("write dnsmasq dhcp options: %w", err)
+	}
+	mainChanged, err := writeFileIfChanged(dnsmasqConfigPath, d.generateConfig(cfg))
+	if err != nil {
+		return "", fmt.Errorf("write dnsmasq config: %w", err)
+	}
+
+	switch {
+	case mainChanged || !d.Status():
+		out, err := exec.Command("systemctl", "restart", dnsmasqUnit).CombinedOutput()
+		if err != nil {
+			return "", fmt.Errorf("restart dnsmasq: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+		return DNSMasqRestarted, nil
+	case hostsChanged || optsChanged:
+		if err := d.reload(); err != nil {
+			return "", err
+		}
+		return DNSMasqReloaded, nil
+	}
+	return DNSMasqUnchanged, nil
+}
+
+// writeFileIfChanged writes content to path unless it already holds exactly that.
+func writeFileIfChanged(path, content string) (bool, error) {
+	if cur, err := os.ReadFile(path); err == nil && string(cur) == content {
+		return false, nil
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return false, err
 	}
+	return true, nil
+}
 
-	// Restart to pick up new config
-	out, err := exec.Command("systemctl", "restart", dnsmasqUnit).CombinedOutput()
+// UpdateHosts rewrites the guest name hosts file and signals dnsmasq to
+// re-read it when the content changed.
+func (d *DNSMasqManager) UpdateHosts(lines []string) error {
+	content := "# Managed by PNAT - do not edit manually\n" + strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		content += "\n"
+	}
+	changed, err := writeFileIfChanged(dnsmasqHostsFile, content)
 	if err != nil {
-		return fmt.Errorf("restart dnsmasq: %w: %s", err, strings.TrimSpace(string(out)))
+		return fmt.Errorf("write dnsmasq hosts: %w", err)
+	}
+	if !changed || !d.Status() {
+		return nil
 	}
+	return d.reload()
+}
 
-	log.Println("dnsmasq config applied and service restarted")
+// reload sends SIGHUP so dnsmasq re-reads its hosts and options files without
+// dropping leases.
+func (d *DNSMasqManager) reload() error {
+	out, err := exec.Command("systemctl", "kill", "--signal=HUP", dnsmasqUnit).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("reload dnsmasq: %w: %s", err, strings.TrimSpace(string(out)))
+	}
 	return nil
 }
 
@@ -69,19 +284,39 @@ func (d *DNSMasqManager) Leases() ([]Lease, error) {
 	defer f.Close()
 
 	var leases []Lease
+	v6 := false
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
-		// Format: timestamp MAC IP hostname clientID
+		// IPv4 format: timestamp MAC IP hostname clientID
+		// After the "duid <server DUID>" line: timestamp IAID IPv6 hostname clientDUID
 		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 1 && fields[0] == "duid" {
+			v6 = true
+			continue
+		}
 		if len(fields) < 4 {
 			continue
 		}
-		leases = append(leases, Lease{
-			Timestamp: fields[0],
-			MAC:       fields[1],
-			IP:        fields[2],
-			Hostname:  fields[3],
-		})
+		l := Lease{Timestamp: fields[0], IP: fields[2]}
+		if ts, err := strconv.ParseInt(fields[0], 10, 64); err == nil && ts > 0 {
+			l.Expires = time.Unix(ts, 0)
+		}
+		if fields[3] != "*" {
+			l.Hostname = fields[3]
+		}
+		var clientID string
+		if len(fields) >= 5 && fields[4] != "*" {
+			clientID = fields[4]
+		}
+		if v6 {
+			l.IAID = fields[1]
+			l.DUID = clientID
+			l.MAC = macFromDUID(clientID)
+		} else {
+			l.MAC = fields[1]
+			l.ClientID = clientID
+		}
+		leases = append(leases, l)
 	}
 	return leases, scanner.Err()
 }
@@ -99,45 +334,320 @@ func (d *DNSMasqManager) stop() error {
 	return nil
 }
 
+func hasDNS(cfg *Config) bool {
+	for _, b := range cfg.Bridges {
+		if b.DNS != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (d *DNSMasqManager) generateConfig(cfg *Config) string {
 	var sb strings.Builder
 
 	sb.WriteString("# Managed by PNAT - do not edit manually\n")
 	sb.WriteString("bind-interfaces\n")
-	sb.WriteString("port=0\n") // DHCP only, no DNS
+	if hasDNS(cfg) {
+		// Serve DNS only on the gateways of DNS-enabled bridges, never on loopback.
+		sb.WriteString("except-interface=lo\n")
+		sb.WriteString("no-hosts\n")
+		sb.WriteString(fmt.Sprintf("addn-hosts=%s\n", dnsmasqHostsFile))
+		// dnsmasq forwards globally, so upstreams of all bridges are merged.
+		seen := map[string]bool{}
+		var upstreams []string
+		for _, b := range cfg.Bridges {
+			if b.DNS == nil {
+				continue
+			}
+			for _, u := range b.DNS.Upstreams {
+				if !seen[u] {
+					seen[u] = true
+					upstreams = append(upstreams, u)
+				}
+			}
+		}
+		if len(upstreams) > 0 {
+			sb.WriteString("no-resolv\n")
+			for _, u := range upstreams {
+				sb.WriteString(fmt.Sprintf("server=%s\n", u))
+			}
+		}
+	} else {
+		sb.WriteString("port=0\n") // DHCP only, no DNS
+	}
 	sb.WriteString("keep-in-foreground\n")
 	sb.WriteString("no-daemon\n")
 	sb.WriteString(fmt.Sprintf("dhcp-leasefile=%s\n", dnsmasqLeaseFile))
+	sb.WriteString(fmt.Sprintf("dhcp-hostsfile=%s\n", dnsmasqDHCPHostsFile))
+	sb.WriteString(fmt.Sprintf("dhcp-optsfile=%s\n", dnsmasqDHCPOptsFile))
+
+	// Network boot: tag UEFI clients by architecture (option 93) and enable
+	// the built-in TFTP server on bridges that have a TFTP root.
+	var tftpIfaces []string
+	uefi := false
+	for _, b := range cfg.Bridges {
+		if b.DHCP == nil || b.DHCP.Boot == nil {
+			continue
+		}
+		if b.DHCP.Boot.UEFIFilename != "" {
+			uefi = true
+		}
+		if b.DHCP.Boot.TFTPRoot != "" {
+			tftpIfaces = append(tftpIfaces, b.Name)
+		}
+	}
+	if uefi {
+		sb.WriteString(fmt.Sprintf("dhcp-match=set:%s,option:client-arch,7\n", uefiTag))
+		sb.WriteString(fmt.Sprintf("dhcp-match=set:%s,option:client-arch,9\n", uefiTag))
+	}
+	if len(tftpIfaces) > 0 {
+		sb.WriteString(fmt.Sprintf("enable-tftp=%s\n", strings.Join(tftpIfaces, ",")))
+	}
+	for _, b := range cfg.Bridges {
+		if b.IPv6 != nil {
+			sb.WriteString("enable-ra\n")
+			break
+		}
+	}
 	sb.WriteString("\n")
 
 	for _, b := range cfg.Bridges {
-		if b.DHCP == nil {
+		if b.DNS != nil {
+			sb.WriteString(fmt.Sprintf("# DNS %s\n", b.Name))
+			sb.WriteString(fmt.Sprintf("listen-address=%s\n", b.GatewayIP))
+			sb.WriteString(fmt.Sprintf("local=/%s/\n", b.DNS.Domain))
+			sb.WriteString(fmt.Sprintf("domain=%s,%s\n", b.DNS.Domain, b.Subnet))
+			for _, rec := range b.DNS.Records {
+				name := qualifyDNSName(rec.Name, b.DNS.Domain)
+				switch rec.Type {
+				case "A":
+					sb.WriteString(fmt.Sprintf("host-record=%s,%s\n", name, rec.Value))
+				case "CNAME":
+					sb.WriteString(fmt.Sprintf("cname=%s,%s\n", name, qualifyDNSName(rec.Value, b.DNS.Domain)))
+				}
+			}
+			sb.WriteString("\n")
+		}
+
+		if b.DHCP == nil && b.IPv6 == nil {
 			continue
 		}
 
 		sb.WriteString(fmt.Sprintf("# Bridge %s\n", b.Name))
 		sb.WriteString(fmt.Sprintf("interface=%s\n", b.Name))
 
+		if v6 := b.IPv6; v6 != nil {
+			if prefix, err := parseCIDRv6(v6.Prefix); err == nil {
+				ones, _ := prefix.Mask.Size()
+				lease := v6.LeaseTime
+				if lease == "" {
+					lease = "12h"
+				}
+				switch v6.Mode {
+				case "slaac":
+					sb.WriteString(fmt.Sprintf("dhcp-range=%s,%s,ra-only,%d,%s\n", b.Name, prefix.IP, ones, lease))
+				case "stateless":
+					sb.WriteString(fmt.Sprintf("dhcp-range=%s,%s,ra-stateless,%d,%s\n", b.Name, prefix.IP, ones, lease))
+				case "stateful":
+					sb.WriteString(fmt.Sprintf("dhcp-range=%s,%s,%s,%d,%s\n", b.Name, v6.RangeStart, v6.RangeEnd, ones, lease))
+				}
+			}
+		}
+
+		if b.DHCP == nil {
+			sb.WriteString("\n")
+			continue
+		}
+
 		leaseTime := b.DHCP.LeaseTime
 		if leaseTime == "" {
 			leaseTime = "12h"
 		}
-		sb.WriteString(fmt.Sprintf("dhcp-range=%s,%s,%s,%s\n",
-			b.Name, b.DHCP.RangeStart, b.DHCP.RangeEnd, leaseTime))
+		// One dhcp-range per pool: the ranges with the exclusions cut out.
+		// dnsmasq never leases reserved addresses dynamically, so exclusions
+		// kept for reservations are left out; pinning stays a hosts-file reload.
+		pools := *b.DHCP
+		pools.Exclusions = nil
+		for _, e := range b.DHCP.Exclusions {
+			if e.Reservation == "" {
+				pools.Exclusions = append(pools.Exclusions, e)
+			}
+		}
+		for _, p := range pools.pools() {
+			sb.WriteString(fmt.Sprintf("dhcp-range=%s,%s,%s,%s\n",
+				b.Name, uint32ToIP(p.lo), uint32ToIP(p.hi), leaseTime))
+		}
+
+		if boot := b.DHCP.Boot; boot != nil {
+			server := ""
+			if boot.NextServer != "" {
+				server = ",," + boot.NextServer
+			}
+			switch {
+			case boot.Filename != "" && boot.UEFIFilename != "":
+				sb.WriteString(fmt.Sprintf("dhcp-boot=tag:%s,tag:!%s,%s%s\n", b.Name, uefiTag, boot.Filename, server))
+				sb.WriteString(fmt.Sprintf("dhcp-boot=tag:%s,tag:%s,%s%s\n", b.Name, uefiTag, boot.UEFIFilename, server))
+			case boot.UEFIFilename != "":
+				sb.WriteString(fmt.Sprintf("dhcp-boot=tag:%s,tag:%s,%s%s\n", b.Name, uefiTag, boot.UEFIFilename, server))
+			default:
+				sb.WriteString(fmt.Sprintf("dhcp-boot=tag:%s,%s%s\n", b.Name, boot.Filename, server))
+			}
+			if boot.TFTPRoot != "" {
+				sb.WriteString(fmt.Sprintf("tftp-root=%s,%s\n", boot.TFTPRoot, b.Name))
+			}
+		}
+
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// generateDHCPOpts renders the dhcp-optsfile: gateway, DNS and extra options
+// per bridge, tagged with the bridge name.
+func (d *DNSMasqManager) generateDHCPOpts(cfg *Config) string {
+	var sb strings.Builder
+	sb.WriteString("# Managed by PNAT - do not edit manually\n")
+	for _, b := range cfg.Bridges {
+		if b.DHCP == nil {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("# Bridge %s\n", b.Name))
 
 		// Gateway (option 3)
-		sb.WriteString(fmt.Sprintf("dhcp-option=%s,3,%s\n", b.Name, b.GatewayIP))
+		sb.WriteString(fmt.Sprintf("%s,3,%s\n", b.Name, b.GatewayIP))
 
-		// DNS servers (option 6)
+		// DNS servers (option 6): the gateway itself when PNAT serves DNS here.
 		dns := b.DHCP.DNS1
 		if b.DHCP.DNS2 != "" {
 			dns += "," + b.DHCP.DNS2
 		}
+		if b.DNS != nil {
+			dns = b.GatewayIP
+		}
 		if dns != "" {
-			sb.WriteString(fmt.Sprintf("dhcp-option=%s,6,%s\n", b.Name, dns))
+			sb.WriteString(fmt.Sprintf("%s,6,%s\n", b.Name, dns))
+		}
+
+		// Extra options
+		for _, o := range b.DHCP.Options {
+			sb.WriteString(fmt.Sprintf("%s,%d,%s\n", b.Name, o.OptionCode(), normalizeOptionValue(o.Value)))
 		}
-		sb.WriteString("\n")
 	}
+	return sb.String()
+}
 
+// generateDHCPHosts renders the dhcp-hostsfile with static reservations.
+func (d *DNSMasqManager) generateDHCPHosts(cfg *Config) string {
+	var sb strings.Builder
+	sb.WriteString("# Managed by PNAT - do not edit manually\n")
+	for _, b := range cfg.Bridges {
+		if b.DHCP == nil || len(b.DHCP.Hosts) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("# Bridge %s\n", b.Name))
+		for _, h := range b.DHCP.Hosts {
+			line := fmt.Sprintf("%s,%s", normalizeMAC(h.MAC), h.IP)
+			if h.Hostname != "" {
+				line += "," + h.Hostname
+			}
+			if h.LeaseTime != "" {
+				line += "," + h.LeaseTime
+			}
+			sb.WriteString(line + "\n")
+		}
+	}
 	return sb.String()
 }
+
+// ReleaseLease removes a lease from dnsmasq. IPv4 leases on a known interface
+// are released with dhcp_release (dnsmasq-utils) while the daemon keeps
+// running; otherwise the daemon is stopped, the lease file edited and the
+// daemon started again. It returns the method used.
+func (d *DNSMasqManager) ReleaseLease(l Lease, iface string) (string, error) {
+	if !l.IsIPv6() && iface != "" && d.Status() {
+		if _, err := exec.LookPath("dhcp_release"); err == nil {
+			args := []string{iface, l.IP, l.MAC}
+			if l.ClientID != "" {
+				args = append(args, l.ClientID)
+			}
+			out, err := exec.Command("dhcp_release", args...).CombinedOutput()
+			if err != nil {
+				log.Printf("WARN: dhcp_release %s: %v: %s", l.IP, err, strings.TrimSpace(string(out)))
+			} else if d.waitLeaseGone(l.IP, 2*time.Second) {
+				return "dhcp_release", nil
+			}
+		}
+	}
+	if err := d.removeLeaseOffline(l.IP); err != nil {
+		return "", err
+	}
+	return "lease file", nil
+}
+
+// waitLeaseGone polls the lease file until ip disappears or timeout passes.
+func (d *DNSMasqManager) waitLeaseGone(ip string, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		leases, err := d.Leases()
+		if err == nil && !hasLeaseIP(leases, ip) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
+
+func hasLeaseIP(leases []Lease, ip string) bool {
+	for _, l := range leases {
+		if l.IP == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// removeLeaseOffline drops ip from the lease file while dnsmasq is stopped,
+// since a running daemon would rewrite the file from memory.
+func (d *DNSMasqManager) removeLeaseOffline(ip string) error {
+	running := d.Status()
+	if running {
+		if err := d.stop(); err != nil {
+			return err
+		}
+	}
+
+	data, err := os.ReadFile(dnsmasqLeaseFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read leases: %w", err)
+	}
+	var kept []string
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && fields[0] != "duid" && fields[2] == ip {
+			continue
+		}
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+	content := strings.Join(kept, "\n")
+	if len(kept) > 0 {
+		content += "\n"
+	}
+	writeErr := os.WriteFile(dnsmasqLeaseFile, []byte(content), 0644)
+
+	if running {
+		out, err := exec.Command("systemctl", "start", dnsmasqUnit).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("start dnsmasq: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	if writeErr != nil {
+		return fmt.Errorf("write leases: %w", writeErr)
+	}
+	return nil
+}
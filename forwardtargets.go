@@ -0,0 +1,263 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A forward can target a guest NIC (vmid and nic) instead of a fixed address.
+// Its int_ip is then resolved from the NIC's reservation, static IP, lease or
+// guest agent addresses on the forward's bridge, and re-resolved whenever the
+// guests are read, so the DNAT rule follows the guest when it is re-addressed.
+// A target without an address on the bridge forwards nowhere until it has one;
+// a target whose guest cannot be read keeps its last address.
+
+// forwardWatchInterval is how often the web server re-resolves forward
+// targets.
+const forwardWatchInterval = 15 * time.Second
+
+// errNoTargetAddress is returned for a target NIC that has no address on the
+// forward's bridge yet.
+var errNoTargetAddress = errors.New("no address known")
+
+// errTargetUnavailable is returned for a target whose guest or NIC could not
+// be read; its last address is kept.
+var errTargetUnavailable = errors.New("target unavailable")
+
+// parseForwardTarget parses a guest NIC target written as "<vmid>:<net key>",
+// e.g. "101:net0".
+func parseForwardTarget(s string) (int, string, error) {
+	id, key, ok := strings.Cut(strings.TrimSpace(s), ":")
+	vmid, err := strconv.Atoi(id)
+	if !ok || err != nil || vmid <= 0 || validateNetKey(key) != nil {
+		return 0, "", fmt.Errorf("invalid target %q (expected VMID:netN, e.g. 101:net0)", s)
+	}
+	return vmid, key, nil
+}
+
+// Target describes the guest NIC a forward targets, e.g. "VM 101 net0" or
+// "CT 102 net0" for a container in vms.
+func (f PortForward) Target(vms []VMView) string {
+	if f.VMID == 0 {
+		return ""
+	}
+	kind := "VM"
+	for _, vm := range vms {
+		if vm.VMID == f.VMID && vm.Type == "lxc" {
+			kind = "CT"
+		}
+	}
+	return fmt.Sprintf("%s %d %s", kind, f.VMID, f.NIC)
+}
+
+// resolveForwardTarget returns the address of the guest NIC forward f on br
+// targets: its reservation, static IP, lease or first guest agent address in
+// the bridge subnet, in that order.
+func resolveForwardTarget(br *BridgeConfig, f PortForward, vms []VMView) (string, error) {
+	var nic *VMNICView
+	for i := range vms {
+		if vms[i].VMID != f.VMID {
+			continue
+		}
+		if vms[i].Incomplete {
+			return "", fmt.Errorf("%w: guest %d could not be read", errTargetUnavailable, f.VMID)
+		}
+		for j := range vms[i].NICs {
+			if vms[i].NICs[j].Key == f.NIC {
+				nic = &vms[i].NICs[j]
+			}
+		}
+		if nic == nil {
+			return "", fmt.Errorf("%w: guest %d has no %s", errTargetUnavailable, f.VMID, f.NIC)
+		}
+	}
+	if nic == nil {
+		return "", fmt.Errorf("%w: guest %d not found", errTargetUnavailable, f.VMID)
+	}
+	if nic.Network != br.Name {
+		return "", fmt.Errorf("%s of guest %d is on %s, not %s", f.NIC, f.VMID, nic.Network, br.Name)
+	}
+
+	ipnet, err := parseCIDRv4(br.Subnet)
+	if err != nil {
+		return "", err
+	}
+	var candidates []string
+	if br.DHCP != nil && nic.MAC != "" {
+		for _, h := range br.DHCP.Hosts {
+			if normalizeMAC(h.MAC) == normalizeMAC(nic.MAC) {
+				candidates = append(candidates, h.IP)
+			}
+		}
+	}
+	candidates = append(candidates, nic.StaticIP, nic.LeaseIP)
+	for _, ip := range nic.AgentIPs {
+		candidates = append(candidates, strings.Split(ip, "/")[0])
+	}
+	for _, c := range candidates {
+		if ip, err := parseIPv4(c); err == nil && ipInNet(ip, ipnet) {
+			return ip.String(), nil
+		}
+	}
+	return "", fmt.Errorf("%w for %s of guest %d", errNoTargetAddress, f.NIC, f.VMID)
+}
+
+// ForwardTargetOption is a guest NIC on a managed bridge offered as a forward
+// target.
+type ForwardTargetOption struct {
+	Value  string // <vmid>:<net key>
+	Label  string
+	Bridge string
+}
+
+// forwardTargetOptions lists the NICs of guests on managed bridges. The caller
+// must hold the config lock.
+func forwardTargetOptions(cfg *Config, vms []VMView) []ForwardTargetOption {
+	var out []ForwardTargetOption
+	for _, vm := range vms {
+		kind := "VM"
+		if vm.Type == "lxc" {
+			kind = "CT"
+		}
+		for _, nic := range vm.NICs {
+			if cfg.FindBridge(nic.Network) == nil {
+				continue
+			}
+			label := fmt.Sprintf("%s %d %s", kind, vm.VMID, nic.Key)
+			if vm.Name != "" {
+				label = fmt.Sprintf("%s %d (%s) %s", kind, vm.VMID, vm.Name, nic.Key)
+			}
+			out = append(out, ForwardTargetOption{
+				Value:  fmt.Sprintf("%d:%s", vm.VMID, nic.Key),
+				Label:  label,
+				Bridge: nic.Network,
+			})
+		}
+	}
+	return out
+}
+
+// ForwardTargets keeps the addresses of forwards that target a guest NIC up
+// to date and remembers why a target could not be resolved.
+type ForwardTargets struct {
+	mu         sync.Mutex
+	unresolved map[string]string // forward ID -> reason
+}
+
+func NewForwardTargets() *ForwardTargets {
+	return &ForwardTargets{unresolved: map[string]string{}}
+}
+
+// Unresolved returns why the target of forward id has no address, or "".
+func (t *ForwardTargets) Unresolved(id string) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.unresolved[id]
+}
+
+// Resolve sets int_ip of every forward that targets a guest NIC and returns
+// the addresses that changed by forward ID, so the rules need to be applied
+// again. A target that is unavailable is only marked unresolved. The caller
+// must hold the config lock.
+func (t *ForwardTargets) Resolve(cfg *Config, vms []VMView) map[string]string {
+	unresolved := map[string]string{}
+	changed := map[string]string{}
+	for i := range cfg.Bridges {
+		br := &cfg.Bridges[i]
+		for j := range br.Forwards {
+			f := &br.Forwards[j]
+			if f.VMID == 0 {
+				continue
+			}
+			ip, err := resolveForwardTarget(br, *f, vms)
+			if err != nil {
+				unresolved[f.ID] = err.Error()
+			}
+			if ip == f.IntIP || errors.Is(err, errTargetUnavailable) {
+				continue
+			}
+			if ip == "" {
+				log.Printf("WARN: forward %s/%d to %s: %v; was %s", f.Protocol, f.ExtPort, f.Target(vms), err, f.IntIP)
+			} else {
+				log.Printf("forward %s/%d to %s: %s -> %s", f.Protocol, f.ExtPort, f.Target(vms), f.IntIP, ip)
+			}
+			f.IntIP = ip
+			changed[f.ID] = ip
+		}
+	}
+	t.mu.Lock()
+	t.unresolved = unresolved
+	t.mu.Unlock()
+	return changed
+}
+
+// resolveForwards re-resolves the forward targets and applies the rules when
+// an address changed. Only the targeted guests are read, and nothing changes
+// while they or the leases cannot be read. The new addresses are written into
+// the saved config rather than saving it whole, so edits made meanwhile by
+// the TUI are kept.
+func (app *App) resolveForwards() {
+	app.cfg.Lock()
+	targeted := map[int]bool{}
+	for _, b := range app.cfg.Bridges {
+		for _, f := range b.Forwards {
+			if f.VMID != 0 {
+				targeted[f.VMID] = true
+			}
+		}
+	}
+	app.cfg.Unlock()
+	if len(targeted) == 0 {
+		return
+	}
+
+	leases, err := app.dnsmasq.Leases()
+	if err != nil {
+		log.Printf("WARN: forward targets: %v", err)
+		return
+	}
+	vms, err := app.proxmox.ListVMs()
+	if err != nil {
+		log.Printf("WARN: forward targets: %v", err)
+		return
+	}
+	vms = slices.DeleteFunc(vms, func(vm VM) bool { return !targeted[vm.VMID] })
+	vmViews := buildVMViews(app.proxmox, vms, leases)
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+	changed := app.targets.Resolve(app.cfg, vmViews)
+	if len(changed) == 0 {
+		return
+	}
+	if err := saveForwardTargets(app.cfg.path, changed); err != nil {
+		log.Printf("ERROR: save config: %v", err)
+	}
+	if err := app.nft.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply nftables: %v", err)
+	}
+}
+
+// saveForwardTargets sets the int_ip of the forwards in changed, by forward
+// ID, in the config saved at path.
+func saveForwardTargets(path string, changed map[string]string) error {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return err
+	}
+	for i := range cfg.Bridges {
+		for j := range cfg.Bridges[i].Forwards {
+			f := &cfg.Bridges[i].Forwards[j]
+			if ip, ok := changed[f.ID]; ok {
+				f.IntIP = ip
+			}
+		}
+	}
+	return cfg.Save()
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"log"
@@ -14,6 +15,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // SetupRoutes registers all HTTP routes.
@@ -65,6 +67,34 @@ func (app *App) SetupRoutes(mux *http.ServeMux) {
 			app.HandleBridgeAttach(w, r)
 		case path == "/bridges/detach" && r.Method == http.MethodPost:
 			app.HandleBridgeDetach(w, r)
+		case strings.HasPrefix(path, "/bridges/edit/") && r.Method == http.MethodGet:
+			app.HandleBridgeEditForm(w, r)
+		case strings.HasPrefix(path, "/bridges/edit/") && r.Method == http.MethodPost:
+			app.HandleBridgeEdit(w, r)
+		case path == "/bridges/delete" && r.Method == http.MethodPost:
+			app.HandleBridgeDelete(w, r)
+		case path == "/bridges/sync" && r.Method == http.MethodPost:
+			app.HandleBridgeSync(w, r)
+		case strings.HasPrefix(path, "/bridges/renumber/") && r.Method == http.MethodGet:
+			app.HandleBridgeRenumberForm(w, r)
+		case strings.HasPrefix(path, "/bridges/renumber/") && r.Method == http.MethodPost:
+			app.HandleBridgeRenumber(w, r)
+		case path == "/network" && r.Method == http.MethodGet:
+			app.HandleNetworkChanges(w, r)
+		case path == "/network/apply" && r.Method == http.MethodPost:
+			app.HandleNetworkApply(w, r)
+		case path == "/network/confirm" && r.Method == http.MethodPost:
+			app.HandleNetworkConfirm(w, r)
+		case path == "/network/revert" && r.Method == http.MethodPost:
+			app.HandleNetworkRevert(w, r)
+		case path == "/inventory/refresh" && r.Method == http.MethodPost:
+			app.HandleInventoryRefresh(w, r)
+		case path == "/vms/nic" && r.Method == http.MethodGet:
+			app.HandleVMNICForm(w, r)
+		case path == "/vms/nic" && r.Method == http.MethodPost:
+			app.HandleVMNICSave(w, r)
+		case path == "/vms/nic/delete" && r.Method == http.MethodPost:
+			app.HandleVMNICDelete(w, r)
 		case path == "/vms/net/update" && r.Method == http.MethodPost:
 			app.HandleVMNetUpdate(w, r)
 		case path == "/dhcp" && r.Method == http.MethodGet:
@@ -73,12 +103,56 @@ func (app *App) SetupRoutes(mux *http.ServeMux) {
 			app.HandleDHCPForm(w, r)
 		case strings.HasPrefix(path, "/dhcp/edit/") && r.Method == http.MethodPost:
 			app.HandleDHCPSave(w, r)
+		case path == "/dhcp/hosts/add" && r.Method == http.MethodPost:
+			app.HandleDHCPHostSave(w, r)
+		case path == "/dhcp/hosts/delete" && r.Method == http.MethodPost:
+			app.HandleDHCPHostDelete(w, r)
+		case path == "/dhcp/options/add" && r.Method == http.MethodPost:
+			app.HandleDHCPOptionAdd(w, r)
+		case path == "/dhcp/options/delete" && r.Method == http.MethodPost:
+			app.HandleDHCPOptionDelete(w, r)
+		case path == "/dhcp/leases/pin" && r.Method == http.MethodPost:
+			app.HandleLeasePin(w, r)
+		case path == "/dhcp/leases/release" && r.Method == http.MethodPost:
+			app.HandleLeaseRelease(w, r)
+		case strings.HasPrefix(path, "/dns/edit/") && r.Method == http.MethodGet:
+			app.HandleDNSForm(w, r)
+		case strings.HasPrefix(path, "/dns/edit/") && r.Method == http.MethodPost:
+			app.HandleDNSSave(w, r)
+		case path == "/dns/records/add" && r.Method == http.MethodPost:
+			app.HandleDNSRecordAdd(w, r)
+		case path == "/dns/records/delete" && r.Method == http.MethodPost:
+			app.HandleDNSRecordDelete(w, r)
 		case path == "/api/vms" && r.Method == http.MethodGet:
 			app.HandleAPIVMs(w, r)
 		case path == "/api/nft-status" && r.Method == http.MethodGet:
 			app.HandleAPINFTStatus(w, r)
 		case path == "/api/dhcp-leases" && r.Method == http.MethodGet:
 			app.HandleAPIDHCPLeases(w, r)
+		case path == "/api/dhcp-leases/release" && r.Method == http.MethodPost:
+			app.HandleAPILeaseRelease(w, r)
+		case path == "/api/inventory" && r.Method == http.MethodGet:
+			app.HandleAPIInventory(w, r)
+		case path == "/api/network" && r.Method == http.MethodGet:
+			app.HandleAPINetwork(w, r)
+		case path == "/api/sdn" && r.Method == http.MethodGet:
+			app.HandleAPISDN(w, r)
+		case path == "/api/cluster" && r.Method == http.MethodGet:
+			app.HandleAPICluster(w, r)
+		case path == "/api/conflicts" && r.Method == http.MethodGet:
+			app.HandleAPIConflicts(w, r)
+		case path == "/api/bridges/renumber" && r.Method == http.MethodGet:
+			app.HandleAPIRenumberPreview(w, r)
+		case path == "/api/subnets/next" && r.Method == http.MethodGet:
+			app.HandleAPISubnetNext(w, r)
+		case path == "/api/ipam/next" && r.Method == http.MethodGet:
+			app.HandleAPIIPAMNext(w, r)
+		case path == "/api/ipam/holds" && r.Method == http.MethodGet:
+			app.HandleAPIIPAMHolds(w, r)
+		case path == "/api/ipam/hold" && r.Method == http.MethodPost:
+			app.HandleAPIIPAMHold(w, r)
+		case path == "/api/ipam/release" && r.Method == http.MethodPost:
+			app.HandleAPIIPAMRelease(w, r)
 		default:
 			http.NotFound(w, r)
 		}
@@ -111,6 +185,10 @@ func (app *App) render(w http.ResponseWriter, name string, data map[string]any)
 			data["LoggedIn"] = true
 		}
 	}
+	if data["LoggedIn"] == true {
+		data["Proxmox"] = app.proxmox.Health()
+		data["Network"] = app.network.Status()
+	}
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	tmpl, ok := app.templates[name]
 	if !ok {
@@ -139,6 +217,7 @@ func (app *App) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 	uplinks := app.buildUplinkViews()
 	leases, _ := app.dnsmasq.Leases()
 	vmViews := buildVMViews(app.proxmox, vms, leases)
+	markReservedNICs(app.cfg, vmViews)
 	usedIPs := buildUsedIPs(app.cfg, leases, vmViews)
 	attachable := make([]BridgeView, 0, len(proxmoxBridges))
 	for _, b := range proxmoxBridges {
@@ -147,8 +226,26 @@ func (app *App) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	conflicts, conflictsScanned, _ := app.conflicts.Results()
+	sdnZones, _ := app.proxmox.ListSDNZones()
+
+	app.cfg.Lock()
+	nextSubnet, nextSubnetErr := app.cfg.NextFreeSubnet(unmanagedBridgeCIDRs(proxmoxBridges))
+	migrated := migratedForwards(app.cfg, vmViews)
+	bridgeNodes, bridgeNames := app.cfg.BridgeNodes, app.cfg.bridgeNames()
+	app.cfg.Unlock()
+	if nextSubnetErr != nil {
+		log.Printf("WARN: suggest subnet: %v", nextSubnetErr)
+	}
+
 	app.render(w, "dashboard.html", map[string]any{
 		"Active":            "dashboard",
+		"Conflicts":         conflicts,
+		"ConflictsScanned":  conflictsScanned,
+		"NextSubnet":        nextSubnet,
+		"Migrated":          migrated,
+		"ClusterBridges":    clusterBridges(app.proxmox, bridgeNodes, bridgeNames),
+		"SDNZones":          sdnZones,
 		"Bridges":           app.cfg.Bridges,
 		"ProxmoxBridges":    proxmoxBridges,
 		"UplinkPorts":       uplinks,
@@ -161,6 +258,17 @@ func (app *App) HandleDashboard(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// HandleInventoryRefresh drops the cached Proxmox inventory and returns to
+// the page it was called from.
+func (app *App) HandleInventoryRefresh(w http.ResponseWriter, r *http.Request) {
+	app.proxmox.Invalidate()
+	back := "/"
+	if u, err := url.Parse(r.Referer()); err == nil && strings.HasPrefix(u.Path, "/") && !strings.HasPrefix(u.Path, "//") && u.Path != "/inventory/refresh" {
+		back = u.Path
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
 // --- NAT Toggle ---
 
 func (app *App) HandleNATToggle(w http.ResponseWriter, r *http.Request) {
@@ -193,29 +301,73 @@ func (app *App) HandleNATToggle(w http.ResponseWriter, r *http.Request) {
 type ForwardView struct {
 	Bridge string
 	PortForward
+	Target     string          // guest NIC the forward targets, e.g. "CT 102 net0"
+	Migrated   *ForwardWarning // target guest runs on another node
+	Unresolved string          // why the target guest NIC has no address
 }
 
 func (app *App) HandleForwardsList(w http.ResponseWriter, r *http.Request) {
+	leases, _ := app.dnsmasq.Leases()
+	vms, _ := app.proxmox.ListVMs()
+	vmViews := buildVMViews(app.proxmox, vms, leases)
+
+	app.cfg.Lock()
+	migrated := map[string]*ForwardWarning{}
+	for _, fw := range migratedForwards(app.cfg, vmViews) {
+		migrated[fw.ForwardID] = &fw
+	}
 	var forwards []ForwardView
 	for _, b := range app.cfg.Bridges {
 		for _, f := range b.Forwards {
-			forwards = append(forwards, ForwardView{Bridge: b.Name, PortForward: f})
+			v := ForwardView{Bridge: b.Name, PortForward: f, Target: f.Target(vmViews), Migrated: migrated[f.ID]}
+			if f.VMID != 0 {
+				v.Unresolved = app.targets.Unresolved(f.ID)
+				if v.Unresolved == "" && f.IntIP == "" {
+					v.Unresolved = "not resolved yet"
+				}
+			}
+			forwards = append(forwards, v)
 		}
 	}
+	targets := forwardTargetOptions(app.cfg, vmViews)
+	app.cfg.Unlock()
 
-	leases, _ := app.dnsmasq.Leases()
-	vms, _ := app.proxmox.ListVMs()
-	vmViews := buildVMViews(app.proxmox, vms, leases)
-	bridgeIPLists := buildBridgeIPLists(app.cfg, vmViews)
+	bridgeIPLists := app.withNextFreeIPs(buildBridgeIPLists(app.cfg, vmViews), buildUsedIPs(app.cfg, leases, vmViews))
 
 	app.render(w, "forwards.html", map[string]any{
 		"Active":        "forwards",
 		"Bridges":       app.cfg.Bridges,
 		"Forwards":      forwards,
+		"Targets":       targets,
 		"BridgeIPLists": bridgeIPLists,
 	})
 }
 
+// withNextFreeIPs puts the next free address of every managed bridge at the
+// top of its suggestion list.
+func (app *App) withNextFreeIPs(lists []BridgeIPList, used []BridgeUsedIPs) []BridgeIPList {
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+	byBridge := map[string]int{}
+	for i, l := range lists {
+		byBridge[l.Bridge] = i
+	}
+	for i := range app.cfg.Bridges {
+		br := &app.cfg.Bridges[i]
+		ip, err := app.ipam.NextFree(br, used, false)
+		if err != nil {
+			continue
+		}
+		opt := BridgeIPOption{IP: ip, Label: "next free"}
+		if j, ok := byBridge[br.Name]; ok {
+			lists[j].Options = append([]BridgeIPOption{opt}, lists[j].Options...)
+		} else {
+			lists = append(lists, BridgeIPList{Bridge: br.Name, Options: []BridgeIPOption{opt}})
+		}
+	}
+	return lists
+}
+
 func (app *App) HandleForwardCreate(w http.ResponseWriter, r *http.Request) {
 	bridgeName := r.FormValue("bridge")
 	protocol := r.FormValue("protocol")
@@ -223,6 +375,7 @@ func (app *App) HandleForwardCreate(w http.ResponseWriter, r *http.Request) {
 	intIP := r.FormValue("int_ip")
 	intPortStr := r.FormValue("int_port")
 	comment := r.FormValue("comment")
+	target := strings.TrimSpace(r.FormValue("target"))
 
 	extPort, err := strconv.ParseUint(extPortStr, 10, 16)
 	if err != nil || extPort == 0 {
@@ -234,18 +387,37 @@ func (app *App) HandleForwardCreate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid internal port", http.StatusBadRequest)
 		return
 	}
-	if net.ParseIP(intIP) == nil {
-		http.Error(w, "Invalid internal IP", http.StatusBadRequest)
-		return
-	}
 	if protocol != "tcp" && protocol != "udp" && protocol != "tcp+udp" {
 		http.Error(w, "Invalid protocol", http.StatusBadRequest)
 		return
 	}
-	intIPv4, err := parseIPv4(intIP)
-	if err != nil {
-		http.Error(w, "Invalid internal IP (IPv4 required)", http.StatusBadRequest)
-		return
+
+	// A forward to a guest NIC gets its address from the guest.
+	var vmid int
+	var nicKey string
+	var vmViews []VMView
+	var intIPv4 net.IP
+	if target != "" {
+		if vmid, nicKey, err = parseForwardTarget(target); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		leases, _ := app.dnsmasq.Leases()
+		vms, err := app.proxmox.ListVMs()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Proxmox API error: %v", err), http.StatusBadRequest)
+			return
+		}
+		vmViews = buildVMViews(app.proxmox, vms, leases)
+	} else {
+		if net.ParseIP(intIP) == nil {
+			http.Error(w, "Invalid internal IP", http.StatusBadRequest)
+			return
+		}
+		if intIPv4, err = parseIPv4(intIP); err != nil {
+			http.Error(w, "Invalid internal IP (IPv4 required)", http.StatusBadRequest)
+			return
+		}
 	}
 
 	id := generateID()
@@ -263,7 +435,7 @@ func (app *App) HandleForwardCreate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Bridge subnet invalid", http.StatusBadRequest)
 		return
 	}
-	if !ipInNet(intIPv4, ipnet) {
+	if intIPv4 != nil && !ipInNet(intIPv4, ipnet) {
 		http.Error(w, "Internal IP not in bridge subnet", http.StatusBadRequest)
 		return
 	}
@@ -280,15 +452,30 @@ func (app *App) HandleForwardCreate(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	br.Forwards = append(br.Forwards, PortForward{
+	f := PortForward{
 		ID:       id,
 		Protocol: protocol,
 		ExtPort:  uint16(extPort),
 		IntIP:    intIP,
 		IntPort:  uint16(intPort),
+		VMID:     vmid,
+		NIC:      nicKey,
 		Comment:  comment,
 		Enabled:  true,
-	})
+	}
+	if vmid != 0 {
+		// Without an address yet the forward waits for one.
+		ip, err := resolveForwardTarget(br, f, vmViews)
+		if err != nil && !errors.Is(err, errNoTargetAddress) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.IntIP, intIP = ip, ip
+	}
+	br.Forwards = append(br.Forwards, f)
+
+	// The forward now marks the address as used; a hold on it is no longer needed.
+	app.ipam.Release(intIP)
 
 	if err := app.cfg.Save(); err != nil {
 		log.Printf("ERROR: save config: %v", err)
@@ -349,58 +536,72 @@ func (app *App) HandleForwardToggle(w http.ResponseWriter, r *http.Request) {
 
 type BridgeView struct {
 	Name      string
+	Type      string // Proxmox interface type, empty for VNets
 	CIDR      string
 	Ports     string
 	Managed   bool
 	HasCIDR   bool
 	Address   string
 	Netmask   string
+	Zone      string // SDN zone for VNets, empty for Linux bridges
+	VLAN      int    // VLAN of a VLAN interface or OVS internal port on Parent
+	Parent    string // bridge of a VLAN interface or OVS internal port
+	VLANAware bool
+	SNAT      bool // VNet subnet has Proxmox SNAT enabled
 	BridgeRaw ProxmoxNetwork
 }
 
-type UplinkView struct {
-	Name string
-	Type string
+// unmanagedBridgeCIDRs returns the addresses of Proxmox bridges PNAT does not
+// manage, so new subnets can steer clear of them.
+func unmanagedBridgeCIDRs(views []BridgeView) []string {
+	var out []string
+	for _, b := range views {
+		if !b.Managed && b.HasCIDR {
+			out = append(out, b.CIDR)
+		}
+	}
+	return out
 }
 
-var ifaceNameRe = regexp.MustCompile(`(?i)^[a-z][a-z0-9_]{1,20}([:\.]\d+)?$`)
-
-func (app *App) buildBridgeViews() []BridgeView {
-	networks, err := app.proxmox.ListNetworks()
+// checkBridgeOverlap rejects a subnet for bridge name that overlaps a Proxmox
+// bridge PNAT does not manage.
+func checkBridgeOverlap(name, subnet string, views []BridgeView) error {
+	ipnet, err := parseCIDRv4(subnet)
 	if err != nil {
-		log.Printf("WARN: failed to list networks: %v", err)
-		return nil
-	}
-
-	managed := map[string]bool{}
-	for _, b := range app.cfg.Bridges {
-		managed[b.Name] = true
+		return fmt.Errorf("invalid subnet %q", subnet)
 	}
-
-	var bridges []BridgeView
-	for _, n := range networks {
-		if n.Type != "bridge" {
+	for _, b := range views {
+		if b.Name == name || b.Managed || !b.HasCIDR {
 			continue
 		}
-		cidr := n.CIDR
-		if cidr == "" && n.Address != "" && n.Netmask != "" {
-			if v, err := cidrFromAddrNetmask(n.Address, n.Netmask); err == nil {
-				cidr = v
-			}
+		if other, err := parseCIDRv4(b.CIDR); err == nil && netsOverlap(ipnet, other) {
+			return fmt.Errorf("subnet %s overlaps Proxmox bridge %s (%s)", subnet, b.Name, b.CIDR)
 		}
-		bridges = append(bridges, BridgeView{
-			Name:    n.Iface,
-			CIDR:    cidr,
-			Ports:   n.BridgePorts,
-			Managed: managed[n.Iface],
-			HasCIDR: cidr != "",
-			Address: n.Address,
-			Netmask: n.Netmask,
-		})
 	}
+	return nil
+}
+
+// checkNewSubnet rejects a subnet for bridge name that overlaps a managed
+// bridge, the WAN network or another Proxmox bridge.
+func (app *App) checkNewSubnet(name, subnet string) error {
+	app.cfg.Lock()
+	err := app.cfg.checkSubnet(name, subnet)
+	app.cfg.Unlock()
+	if err != nil {
+		return err
+	}
+	return checkBridgeOverlap(name, subnet, app.buildBridgeViews())
+}
+
+type UplinkView struct {
+	Name string
+	Type string
+}
+
+var ifaceNameRe = regexp.MustCompile(`(?i)^[a-z][a-z0-9_]{1,20}([:\.]\d+)?$`)
 
-	sort.Slice(bridges, func(i, j int) bool { return bridges[i].Name < bridges[j].Name })
-	return bridges
+func (app *App) buildBridgeViews() []BridgeView {
+	return buildBridgeViews(app.proxmox, app.cfg)
 }
 
 func (app *App) buildUplinkViews() []UplinkView {
@@ -412,11 +613,11 @@ func (app *App) buildUplinkViews() []UplinkView {
 	var uplinks []UplinkView
 	for _, n := range networks {
 		switch n.Type {
-		case "eth", "bond", "vlan":
+		case "eth", "bond", "vlan", "OVSBond", "OVSPort":
 		default:
 			continue
 		}
-		if n.CIDR != "" || n.Address != "" {
+		if n.CIDR != "" || n.Address != "" || n.OVSBridge != "" {
 			continue
 		}
 		uplinks = append(uplinks, UplinkView{Name: n.Iface, Type: n.Type})
@@ -431,6 +632,9 @@ func (app *App) HandleBridgeCreate(w http.ResponseWriter, r *http.Request) {
 	gateway := strings.TrimSpace(r.FormValue("gateway_ip"))
 	natEnabled := r.FormValue("nat_enabled") == "1"
 	bridgePorts := strings.TrimSpace(r.FormValue("bridge_ports"))
+	zone := strings.TrimSpace(r.FormValue("sdn_zone"))
+	typ := strings.TrimSpace(r.FormValue("type"))
+	ovsBridge := strings.TrimSpace(r.FormValue("ovs_bridge"))
 	dhcpEnabled := r.FormValue("dhcp_enabled") == "1"
 	rangeStart := strings.TrimSpace(r.FormValue("range_start"))
 	rangeEnd := strings.TrimSpace(r.FormValue("range_end"))
@@ -446,6 +650,61 @@ func (app *App) HandleBridgeCreate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid bridge name. Allowed: letters, цифры, '_' (без '-') и длина 2-21 символ.", http.StatusBadRequest)
 		return
 	}
+	if zone != "" {
+		if !sdnIDRe.MatchString(zone) || !sdnIDRe.MatchString(name) {
+			http.Error(w, "Invalid SDN zone or VNet name. Allowed: lowercase letters and digits, 2-8 characters.", http.StatusBadRequest)
+			return
+		}
+		if bridgePorts != "" {
+			http.Error(w, "SDN VNets have no bridge ports", http.StatusBadRequest)
+			return
+		}
+	}
+	vlan, tag := 0, 0
+	if v := strings.TrimSpace(r.FormValue("vlan")); v != "" {
+		var err error
+		if vlan, err = strconv.Atoi(v); err != nil || !validVLAN(vlan) {
+			http.Error(w, "VLAN must be between 1 and 4094", http.StatusBadRequest)
+			return
+		}
+	}
+	switch typ {
+	case "", "bridge":
+		typ = "bridge"
+		if vlan == 0 {
+			break
+		}
+		// With a VLAN, name is the VLAN-aware bridge and the network is
+		// its VLAN interface.
+		if zone != "" || bridgePorts != "" {
+			http.Error(w, "A VLAN network has no SDN zone or bridge ports", http.StatusBadRequest)
+			return
+		}
+		if err := checkVLANBridge(app.proxmox, name); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name = vlanNetworkName(name, vlan)
+	case ovsBridgeType:
+		if zone != "" || vlan != 0 {
+			http.Error(w, "An OVS bridge has no SDN zone or VLAN; create an OVS internal port for a VLAN", http.StatusBadRequest)
+			return
+		}
+	case ovsIntPortType:
+		// The VLAN is the tag of the port on its OVS bridge.
+		if zone != "" || bridgePorts != "" {
+			http.Error(w, "An OVS internal port has no SDN zone or bridge ports", http.StatusBadRequest)
+			return
+		}
+		if err := checkOVSBridge(app.proxmox, ovsBridge); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tag, vlan = vlan, 0
+	default:
+		http.Error(w, "Invalid bridge type", http.StatusBadRequest)
+		return
+	}
 	if subnet == "" || gateway == "" {
 		http.Error(w, "Subnet and gateway IP are required", http.StatusBadRequest)
 		return
@@ -459,6 +718,10 @@ func (app *App) HandleBridgeCreate(w http.ResponseWriter, r *http.Request) {
 	if normalized, err := subnetFromCIDR(subnet); err == nil {
 		subnet = normalized
 	}
+	if err := app.checkNewSubnet(name, subnet); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if dhcpEnabled {
 		if rangeStart == "" || rangeEnd == "" {
 			http.Error(w, "DHCP range start/end are required", http.StatusBadRequest)
@@ -489,7 +752,7 @@ func (app *App) HandleBridgeCreate(w http.ResponseWriter, r *http.Request) {
 	app.cfg.Lock()
 	exists := app.cfg.FindBridge(name) != nil
 	app.cfg.Unlock()
-	if exists {
+	if exists || app.network.IsStaged(name) {
 		http.Error(w, "Bridge already managed by PNAT", http.StatusBadRequest)
 		return
 	}
@@ -498,7 +761,7 @@ func (app *App) HandleBridgeCreate(w http.ResponseWriter, r *http.Request) {
 		uplinks := app.buildUplinkViews()
 		allowed := false
 		for _, u := range uplinks {
-			if u.Name == bridgePorts {
+			if u.Name == bridgePorts && bridgePortFits(typ, u.Type) {
 				allowed = true
 				break
 			}
@@ -509,21 +772,12 @@ func (app *App) HandleBridgeCreate(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Create bridge via Proxmox API
-	if err := app.proxmox.CreateBridge(name, cidr, bridgePorts); err != nil {
-		http.Error(w, fmt.Sprintf("Proxmox API error: %v", err), http.StatusBadRequest)
-		return
-	}
-	if err := app.proxmox.ReloadNetwork(); err != nil {
-		http.Error(w, fmt.Sprintf("Proxmox reload error: %v", err), http.StatusBadRequest)
-		return
-	}
-
-	app.cfg.Lock()
 	br := BridgeConfig{
 		Name:       name,
 		Subnet:     subnet,
 		GatewayIP:  gateway,
+		SDNZone:    zone,
+		VLAN:       vlan,
 		NATEnabled: natEnabled,
 	}
 	if dhcpEnabled {
@@ -535,6 +789,39 @@ func (app *App) HandleBridgeCreate(w http.ResponseWriter, r *http.Request) {
 			DNS2:       dns2,
 		}
 	}
+
+	if zone == "" {
+		// Stage the bridge, VLAN interface or OVS internal port via Proxmox
+		// API; it is managed once the pending network changes are reviewed
+		// and applied.
+		create := app.proxmox.CreateBridge
+		switch {
+		case vlan != 0:
+			create = func(iface, cidr, _ string) error { return app.proxmox.CreateVLAN(iface, cidr) }
+		case typ == ovsBridgeType:
+			create = app.proxmox.CreateOVSBridge
+		case typ == ovsIntPortType:
+			create = func(iface, cidr, _ string) error { return app.proxmox.CreateOVSIntPort(iface, ovsBridge, tag, cidr) }
+		}
+		if err := create(name, cidr, bridgePorts); err != nil {
+			http.Error(w, fmt.Sprintf("Proxmox API error: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := app.network.Stage(br); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, "/network", http.StatusSeeOther)
+		return
+	}
+
+	// Create a VNet in a simple SDN zone via the cluster SDN API
+	if err := createSDNVNet(app.proxmox, zone, name, subnet, gateway); err != nil {
+		http.Error(w, fmt.Sprintf("Proxmox SDN error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	app.cfg.Lock()
 	app.cfg.Bridges = append(app.cfg.Bridges, br)
 	if err := app.cfg.Save(); err != nil {
 		log.Printf("ERROR: save config: %v", err)
@@ -542,7 +829,7 @@ func (app *App) HandleBridgeCreate(w http.ResponseWriter, r *http.Request) {
 	if err := app.nft.Apply(app.cfg); err != nil {
 		log.Printf("ERROR: apply nftables: %v", err)
 	}
-	if err := app.dnsmasq.Apply(app.cfg); err != nil {
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
 		log.Printf("ERROR: apply dnsmasq: %v", err)
 	}
 	app.cfg.Unlock()
@@ -575,11 +862,16 @@ func (app *App) HandleBridgeAttach(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var cidr string
+	var cidr, zone string
+	parent, vlan := splitVLANNetwork(name)
 	for _, n := range networks {
-		if n.Iface != name || n.Type != "bridge" {
+		if n.Iface != name || !isNetworkType(n.Type) {
 			continue
 		}
+		if n.Type != "vlan" {
+			// An OVS internal port carries its tag in its settings.
+			vlan = 0
+		}
 		if n.CIDR != "" {
 			cidr = n.CIDR
 		} else if n.Address != "" && n.Netmask != "" {
@@ -590,10 +882,29 @@ func (app *App) HandleBridgeAttach(w http.ResponseWriter, r *http.Request) {
 		}
 		break
 	}
+	if cidr == "" {
+		// Not a bridge or port on this node; try the SDN VNets.
+		for _, v := range listVNetViews(app.proxmox, nil) {
+			if v.Name != name {
+				continue
+			}
+			if v.SNAT && natEnabled {
+				http.Error(w, "VNet subnet has SNAT enabled in Proxmox SDN; disable it there or attach without NAT", http.StatusBadRequest)
+				return
+			}
+			cidr, zone = v.CIDR, v.Zone
+		}
+	}
 	if cidr == "" {
 		http.Error(w, "Bridge has no IP/CIDR configured in Proxmox", http.StatusBadRequest)
 		return
 	}
+	if vlan != 0 {
+		if err := checkVLANBridge(app.proxmox, parent); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
 
 	ip, ipnet, err := net.ParseCIDR(cidr)
 	if err != nil {
@@ -607,6 +918,10 @@ func (app *App) HandleBridgeAttach(w http.ResponseWriter, r *http.Request) {
 	}
 	ones, _ := ipnet.Mask.Size()
 	subnet := fmt.Sprintf("%s/%d", ipv4.Mask(ipnet.Mask).String(), ones)
+	if err := app.checkNewSubnet(name, subnet); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	if dhcpEnabled {
 		if rangeStart == "" || rangeEnd == "" {
@@ -644,6 +959,8 @@ func (app *App) HandleBridgeAttach(w http.ResponseWriter, r *http.Request) {
 		Name:       name,
 		Subnet:     subnet,
 		GatewayIP:  ipv4.String(),
+		SDNZone:    zone,
+		VLAN:       vlan,
 		NATEnabled: natEnabled,
 	}
 	if dhcpEnabled {
@@ -662,115 +979,1087 @@ func (app *App) HandleBridgeAttach(w http.ResponseWriter, r *http.Request) {
 	if err := app.nft.Apply(app.cfg); err != nil {
 		log.Printf("ERROR: apply nftables: %v", err)
 	}
-	if err := app.dnsmasq.Apply(app.cfg); err != nil {
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
 		log.Printf("ERROR: apply dnsmasq: %v", err)
 	}
+	if zone == "" {
+		if err := syncBridgeNodes(app.proxmox, app.cfg.BridgeNodes, []string{name}); err != nil {
+			log.Printf("ERROR: copy bridge %s to cluster nodes: %v", name, err)
+		}
+	}
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
-func (app *App) HandleBridgeDetach(w http.ResponseWriter, r *http.Request) {
-	name := strings.TrimSpace(r.FormValue("name"))
-	if name == "" {
-		http.Error(w, "Bridge name is required", http.StatusBadRequest)
-		return
-	}
-
-	app.cfg.Lock()
-	defer app.cfg.Unlock()
+// renumberInputs reads what a renumbering plan needs from Proxmox: static
+// guest IPs on the bridge and the other Proxmox bridges.
+func (app *App) renumberInputs(name string) (map[string]string, []BridgeView) {
+	vms, _ := app.proxmox.ListVMs()
+	return staticGuestIPs(buildVMViews(app.proxmox, vms, nil), name), app.buildBridgeViews()
+}
 
-	if !app.cfg.DeleteBridge(name) {
-		http.Error(w, "Bridge not managed by PNAT", http.StatusBadRequest)
-		return
+// planRenumber builds the renumbering plan for bridge name. The caller must
+// hold the config lock.
+func (app *App) planRenumber(name, subnet string, static map[string]string, views []BridgeView) (*RenumberPlan, error) {
+	plan, err := app.cfg.PlanRenumber(name, subnet, static)
+	if err != nil {
+		return nil, err
 	}
-	if err := app.cfg.Save(); err != nil {
-		log.Printf("ERROR: save config: %v", err)
+	if err := checkBridgeOverlap(name, plan.NewSubnet, views); err != nil {
+		return nil, err
 	}
-	if err := app.nft.Apply(app.cfg); err != nil {
-		log.Printf("ERROR: apply nftables: %v", err)
+	return plan, nil
+}
+
+// HandleBridgeRenumberForm asks for the new subnet of a managed bridge and,
+// once one is given, previews every address that will change.
+func (app *App) HandleBridgeRenumberForm(w http.ResponseWriter, r *http.Request) {
+	name := pathParam(r.URL.Path, "/bridges/renumber/")
+	subnet := strings.TrimSpace(r.URL.Query().Get("subnet"))
+
+	app.cfg.Lock()
+	br := app.cfg.FindBridge(name)
+	var current string
+	if br != nil {
+		current = br.Subnet
 	}
-	if err := app.dnsmasq.Apply(app.cfg); err != nil {
-		log.Printf("ERROR: apply dnsmasq: %v", err)
+	app.cfg.Unlock()
+	if br == nil {
+		http.Error(w, "Bridge not found", http.StatusNotFound)
+		return
 	}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	data := map[string]any{
+		"Active":     "dashboard",
+		"BridgeName": name,
+		"Current":    current,
+		"Subnet":     subnet,
+	}
+	if subnet == "" {
+		extra := unmanagedBridgeCIDRs(app.buildBridgeViews())
+		app.cfg.Lock()
+		if next, err := app.cfg.NextFreeSubnet(extra); err == nil {
+			data["Suggested"] = next.Subnet
+		}
+		app.cfg.Unlock()
+	} else {
+		static, views := app.renumberInputs(name)
+		app.cfg.Lock()
+		plan, err := app.planRenumber(name, subnet, static, views)
+		app.cfg.Unlock()
+		if err != nil {
+			data["Error"] = err.Error()
+		} else {
+			data["Plan"] = plan
+		}
+	}
+	app.render(w, "renumber.html", data)
 }
 
-// --- VM Networks (Proxmox API) ---
-
-func (app *App) HandleVMNetUpdate(w http.ResponseWriter, r *http.Request) {
-	vmidStr := strings.TrimSpace(r.FormValue("vmid"))
-	vmType := strings.TrimSpace(r.FormValue("type"))
-	netKey := strings.TrimSpace(r.FormValue("net"))
-	newBridge := strings.TrimSpace(r.FormValue("bridge"))
+// HandleBridgeRenumber stages moving a managed bridge to form value subnet
+// and shows the pending network changes: the Proxmox bridge address,
+// forwards, reservations, DHCP ranges and DNS records change together once
+// they are applied, and go back together if they are reverted.
+func (app *App) HandleBridgeRenumber(w http.ResponseWriter, r *http.Request) {
+	name := pathParam(r.URL.Path, "/bridges/renumber/")
+	subnet := strings.TrimSpace(r.FormValue("subnet"))
 
-	vmid, err := strconv.Atoi(vmidStr)
-	if err != nil || vmid <= 0 {
-		http.Error(w, "Invalid VMID", http.StatusBadRequest)
+	static, views := app.renumberInputs(name)
+	app.cfg.Lock()
+	plan, err := app.planRenumber(name, subnet, static, views)
+	nodes := app.cfg.BridgeNodes
+	app.cfg.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if vmType != "qemu" && vmType != "lxc" {
-		http.Error(w, "Invalid VM type", http.StatusBadRequest)
+
+	if err := stageRenumber(app.proxmox, app.network, nodes, plan); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if !ifaceNameRe.MatchString(newBridge) {
-		http.Error(w, "Invalid bridge name", http.StatusBadRequest)
+	http.Redirect(w, r, "/network", http.StatusSeeOther)
+}
+
+// HandleBridgeSync creates the managed bridges that are missing on the nodes
+// listed in bridge_nodes.
+func (app *App) HandleBridgeSync(w http.ResponseWriter, r *http.Request) {
+	app.cfg.Lock()
+	nodes, names := app.cfg.BridgeNodes, app.cfg.bridgeNames()
+	app.cfg.Unlock()
+
+	if err := syncBridgeNodes(app.proxmox, nodes, names); err != nil {
+		http.Error(w, fmt.Sprintf("Proxmox API error: %v", err), http.StatusBadRequest)
 		return
 	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
 
-	// Default to net0 if not provided.
-	if netKey == "" {
-		netKey = "net0"
+// findBridgeNetwork returns the Linux or OVS bridge name on this node and its
+// CIDR.
+func (app *App) findBridgeNetwork(name string) (*ProxmoxNetwork, string, error) {
+	networks, err := app.proxmox.ListNetworks()
+	if err != nil {
+		return nil, "", err
 	}
-	if err := validateNetKey(netKey); err != nil {
-		http.Error(w, "Invalid net key", http.StatusBadRequest)
+	for i, n := range networks {
+		if n.Iface != name || !isBridgeType(n.Type) {
+			continue
+		}
+		cidr := n.CIDR
+		if cidr == "" && n.Address != "" && n.Netmask != "" {
+			cidr, _ = cidrFromAddrNetmask(n.Address, n.Netmask)
+		}
+		return &networks[i], cidr, nil
+	}
+	return nil, "", fmt.Errorf("no Linux or OVS bridge %s on this node", name)
+}
+
+// HandleBridgeEditForm shows the settings of a Proxmox bridge.
+func (app *App) HandleBridgeEditForm(w http.ResponseWriter, r *http.Request) {
+	name := pathParam(r.URL.Path, "/bridges/edit/")
+	n, cidr, err := app.findBridgeNetwork(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
+	app.cfg.Lock()
+	managed := app.cfg.FindBridge(name) != nil
+	wan := app.cfg.WanInterface
+	app.cfg.Unlock()
+	mtu, _ := n.MTU.Int64()
+
+	app.render(w, "bridge_form.html", map[string]any{
+		"Active":      "dashboard",
+		"Title":       "Edit " + name,
+		"BridgeName":  name,
+		"CIDR":        cidr,
+		"CIDRLocked":  managed || name == wan,
+		"Managed":     managed,
+		"Ports":       n.Ports(),
+		"OVS":         n.Type == ovsBridgeType,
+		"MTU":         mtu,
+		"Comments":    strings.TrimSpace(n.Comments),
+		"Autostart":   n.Autostart == 1,
+		"UplinkPorts": app.buildUplinkViews(),
+	})
+}
 
-	cfg, err := app.proxmox.GetVMConfig(vmType, vmid)
+// HandleBridgeEdit stages new settings of a Proxmox bridge for review. The
+// address of a managed bridge is changed by renumbering it.
+func (app *App) HandleBridgeEdit(w http.ResponseWriter, r *http.Request) {
+	name := pathParam(r.URL.Path, "/bridges/edit/")
+	_, curCIDR, err := app.findBridgeNetwork(name)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Proxmox API error: %v", err), http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	s := BridgeSettings{
+		CIDR:      strings.TrimSpace(r.FormValue("cidr")),
+		Ports:     strings.Join(strings.Fields(r.FormValue("bridge_ports")), " "),
+		Comments:  strings.TrimSpace(r.FormValue("comments")),
+		Autostart: r.FormValue("autostart") == "1",
+	}
+	if v := strings.TrimSpace(r.FormValue("mtu")); v != "" {
+		if s.MTU, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "Invalid MTU", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := validateBridgeSettings(s); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	cur := cfg[netKey]
 
-	var next string
-	if cur == "" {
-		// Add a default NIC only for QEMU.
-		if vmType != "qemu" {
-			http.Error(w, "LXC net add is not supported here (create net in CT config first)", http.StatusBadRequest)
+	app.cfg.Lock()
+	locked := app.cfg.FindBridge(name) != nil || name == app.cfg.WanInterface
+	app.cfg.Unlock()
+	if locked {
+		s.CIDR = curCIDR
+	} else if s.CIDR != "" && s.CIDR != curCIDR {
+		subnet, err := subnetFromCIDR(s.CIDR)
+		if err != nil {
+			http.Error(w, "Invalid CIDR (expected IPv4 address/prefix)", http.StatusBadRequest)
+			return
+		}
+		if err := app.checkNewSubnet(name, subnet); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		// Proxmox accepts: "virtio,bridge=vmbrX" and will generate a MAC.
-		next = fmt.Sprintf("virtio,bridge=%s", newBridge)
-	} else {
-		next = updateBridgeInNetString(cur, newBridge)
 	}
 
-	values := url.Values{}
-	values.Set(netKey, next)
-	if err := app.proxmox.SetVMConfig(vmType, vmid, values); err != nil {
+	if err := app.proxmox.UpdateBridge(name, s); err != nil {
 		http.Error(w, fmt.Sprintf("Proxmox API error: %v", err), http.StatusBadRequest)
 		return
 	}
 
-	http.Redirect(w, r, "/", http.StatusSeeOther)
+	http.Redirect(w, r, "/network", http.StatusSeeOther)
 }
 
-// --- DHCP ---
-
-func (app *App) HandleDHCPList(w http.ResponseWriter, r *http.Request) {
+// HandleBridgeDelete stages the deletion of a Proxmox bridge no guest uses
+// and shows the pending network changes. A managed bridge is only deleted with
+// detach=1; its PNAT config, rules and DHCP are dropped once the deletion is
+// applied.
+func (app *App) HandleBridgeDelete(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	detach := r.FormValue("detach") == "1"
+	if !ifaceNameRe.MatchString(name) {
+		http.Error(w, "Invalid bridge name", http.StatusBadRequest)
+		return
+	}
+
+	app.cfg.Lock()
+	br := app.cfg.FindBridge(name)
+	managed := br != nil
+	vnet := managed && br.SDNZone != ""
+	wan := app.cfg.WanInterface
+	app.cfg.Unlock()
+	if managed && !detach {
+		http.Error(w, "Bridge is managed by PNAT; use Detach & Delete", http.StatusBadRequest)
+		return
+	}
+	if vnet {
+		http.Error(w, "Bridge is an SDN VNet; delete it in Proxmox SDN", http.StatusBadRequest)
+		return
+	}
+
+	if err := deleteBridge(app.proxmox, wan, name); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := app.network.StageDelete(name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/network", http.StatusSeeOther)
+}
+
+func (app *App) HandleBridgeDetach(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		http.Error(w, "Bridge name is required", http.StatusBadRequest)
+		return
+	}
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+
+	if !app.cfg.DeleteBridge(name) {
+		http.Error(w, "Bridge not managed by PNAT", http.StatusBadRequest)
+		return
+	}
+	if err := app.cfg.Save(); err != nil {
+		log.Printf("ERROR: save config: %v", err)
+	}
+	if err := app.nft.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply nftables: %v", err)
+	}
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply dnsmasq: %v", err)
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// --- Network changes (Proxmox API) ---
+
+// HandleNetworkChanges shows the network changes staged in Proxmox, or the
+// confirmation of applied ones.
+func (app *App) HandleNetworkChanges(w http.ResponseWriter, r *http.Request) {
+	status, err := app.network.Pending(app.proxmox)
+	data := map[string]any{
+		"Title":      "Network Changes",
+		"Active":     "dashboard",
+		"Status":     status,
+		"Timeout":    int(defaultNetworkConfirm.Seconds()),
+		"MaxTimeout": int(maxNetworkConfirm.Seconds()),
+	}
+	if err != nil {
+		data["Error"] = fmt.Sprintf("Proxmox API error: %v", err)
+	}
+	app.render(w, "network.html", data)
+}
+
+// HandleNetworkApply reloads the network with the staged changes and arms
+// the safety timer.
+func (app *App) HandleNetworkApply(w http.ResponseWriter, r *http.Request) {
+	timeout, err := parseConfirmTimeout(r.FormValue("confirm_timeout"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := app.network.Apply(app.proxmox, timeout); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if timeout == 0 {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/network", http.StatusSeeOther)
+}
+
+// HandleNetworkConfirm keeps the applied network changes and copies new
+// bridges to the bridge nodes.
+func (app *App) HandleNetworkConfirm(w http.ResponseWriter, r *http.Request) {
+	if !app.network.Confirm() {
+		http.Redirect(w, r, "/network", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// HandleNetworkRevert undoes applied network changes that are not confirmed
+// yet, or else discards the changes staged in Proxmox.
+func (app *App) HandleNetworkRevert(w http.ResponseWriter, r *http.Request) {
+	if err := app.network.Revert(app.proxmox); err != nil {
+		http.Error(w, fmt.Sprintf("Revert network changes: %v", err), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/network", http.StatusSeeOther)
+}
+
+// --- VM Networks (Proxmox API) ---
+
+func (app *App) HandleVMNetUpdate(w http.ResponseWriter, r *http.Request) {
+	vmidStr := strings.TrimSpace(r.FormValue("vmid"))
+	vmType := strings.TrimSpace(r.FormValue("type"))
+	netKey := strings.TrimSpace(r.FormValue("net"))
+	newBridge := strings.TrimSpace(r.FormValue("bridge"))
+
+	vmid, err := strconv.Atoi(vmidStr)
+	if err != nil || vmid <= 0 {
+		http.Error(w, "Invalid VMID", http.StatusBadRequest)
+		return
+	}
+	if vmType != "qemu" && vmType != "lxc" {
+		http.Error(w, "Invalid VM type", http.StatusBadRequest)
+		return
+	}
+	if !ifaceNameRe.MatchString(newBridge) {
+		http.Error(w, "Invalid bridge name", http.StatusBadRequest)
+		return
+	}
+
+	// Default to net0 if not provided.
+	if netKey == "" {
+		netKey = "net0"
+	}
+	if err := validateNetKey(netKey); err != nil {
+		http.Error(w, "Invalid net key", http.StatusBadRequest)
+		return
+	}
+
+	vmCfg, err := app.proxmox.GetVMConfigFresh(vmType, vmid)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Proxmox API error: %v", err), http.StatusBadRequest)
+		return
+	}
+	cur := vmCfg[netKey]
+
+	var next string
+	if cur == "" {
+		// Add a default NIC; Proxmox generates the MAC.
+		bridge, tag := app.guestNetwork(newBridge)
+		spec := NICSpec{Model: "virtio", Bridge: bridge, Tag: tag}
+		if vmType == "lxc" {
+			spec = NICSpec{Name: nextLXCNICName(vmCfg), Bridge: bridge, Tag: tag, IP: "dhcp"}
+		}
+		if next, err = buildNetString(vmType, "", spec); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	} else {
+		bridge, tag := app.guestNetwork(newBridge)
+		next = setNICNetwork(cur, bridge, tag)
+	}
+
+	values := url.Values{}
+	values.Set(netKey, next)
+	if err := app.proxmox.SetVMConfig(vmType, vmid, values); err != nil {
+		http.Error(w, fmt.Sprintf("Proxmox API error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := app.autoReserveNIC(vmType, vmid, vmCfg["name"], vmCfg["hostname"], netKey, newBridge); err != nil {
+		log.Printf("WARN: auto-reserve %s/%d %s: %v", vmType, vmid, netKey, err)
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// vmNICParams reads and checks the guest type, VMID and optional net key of a
+// NIC request.
+func vmNICParams(r *http.Request) (vmType string, vmid int, netKey string, err error) {
+	vmType = strings.TrimSpace(r.FormValue("type"))
+	if vmType != "qemu" && vmType != "lxc" {
+		return "", 0, "", fmt.Errorf("invalid VM type")
+	}
+	vmid, err = strconv.Atoi(strings.TrimSpace(r.FormValue("vmid")))
+	if err != nil || vmid <= 0 {
+		return "", 0, "", fmt.Errorf("invalid VMID")
+	}
+	netKey = strings.TrimSpace(r.FormValue("net"))
+	if netKey != "" {
+		if err := validateNetKey(netKey); err != nil {
+			return "", 0, "", err
+		}
+	}
+	return vmType, vmid, netKey, nil
+}
+
+// HandleVMNICForm shows the form to add a NIC to a guest, or to edit netKey.
+func (app *App) HandleVMNICForm(w http.ResponseWriter, r *http.Request) {
+	vmType, vmid, netKey, err := vmNICParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	vmCfg, err := app.proxmox.GetVMConfigFresh(vmType, vmid)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Proxmox API error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	name := vmCfg["name"]
+	if vmType == "lxc" {
+		name = vmCfg["hostname"]
+	}
+	isNew := netKey == ""
+	var spec NICSpec
+	if isNew {
+		if netKey = nextNetKey(vmType, vmCfg); netKey == "" {
+			http.Error(w, fmt.Sprintf("guest %d has no free NIC slot (max %d)", vmid, maxGuestNICs[vmType]), http.StatusBadRequest)
+			return
+		}
+		spec = NICSpec{Model: "virtio", Name: nextLXCNICName(vmCfg), IP: "dhcp"}
+	} else {
+		if vmCfg[netKey] == "" {
+			http.Error(w, fmt.Sprintf("guest %d has no %s", vmid, netKey), http.StatusNotFound)
+			return
+		}
+		spec = parseNICSpec(vmType, vmCfg[netKey])
+	}
+
+	app.render(w, "vmnic.html", map[string]any{
+		"Active":        "dashboard",
+		"Title":         "Guest NIC",
+		"Type":          vmType,
+		"VMID":          vmid,
+		"VMName":        name,
+		"Key":           netKey,
+		"New":           isNew,
+		"Spec":          spec,
+		"Models":        qemuNICModels,
+		"BridgeOptions": app.buildBridgeNameOptions(app.buildBridgeViews()),
+	})
+}
+
+// HandleVMNICSave adds or updates a guest NIC.
+func (app *App) HandleVMNICSave(w http.ResponseWriter, r *http.Request) {
+	vmType, vmid, netKey, err := vmNICParams(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if netKey == "" {
+		http.Error(w, "Invalid net key", http.StatusBadRequest)
+		return
+	}
+	spec := NICSpec{
+		Model:    strings.TrimSpace(r.FormValue("model")),
+		Name:     strings.TrimSpace(r.FormValue("name")),
+		MAC:      strings.TrimSpace(r.FormValue("mac")),
+		Bridge:   strings.TrimSpace(r.FormValue("bridge")),
+		Firewall: r.FormValue("firewall") == "1",
+		Rate:     strings.TrimSpace(r.FormValue("rate")),
+		IP:       strings.TrimSpace(r.FormValue("ip")),
+		GW:       strings.TrimSpace(r.FormValue("gw")),
+	}
+	if tag := strings.TrimSpace(r.FormValue("tag")); tag != "" {
+		if spec.Tag, err = strconv.Atoi(tag); err != nil || spec.Tag == 0 {
+			http.Error(w, "VLAN tag must be between 1 and 4094", http.StatusBadRequest)
+			return
+		}
+	}
+	// A VLAN network or OVS internal port is joined with its bridge and tag.
+	if bridge, tag := app.guestNetwork(spec.Bridge); bridge != spec.Bridge {
+		spec.Bridge, spec.Tag = bridge, tag
+	}
+
+	vmCfg, err := app.proxmox.GetVMConfigFresh(vmType, vmid)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Proxmox API error: %v", err), http.StatusBadRequest)
+		return
+	}
+	// The key of a new NIC was picked when the form was shown; refuse rather
+	// than overwrite a NIC added since, or recreate one removed since.
+	cur := vmCfg[netKey]
+	if isNew := r.FormValue("new") == "1"; isNew && cur != "" {
+		http.Error(w, fmt.Sprintf("%s of guest %d was added in the meantime; reload the form", netKey, vmid), http.StatusConflict)
+		return
+	} else if !isNew && cur == "" {
+		http.Error(w, fmt.Sprintf("guest %d has no %s", vmid, netKey), http.StatusNotFound)
+		return
+	}
+	next, err := buildNetString(vmType, cur, spec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkNICSpec(vmType, vmCfg, netKey, spec); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	values := url.Values{}
+	values.Set(netKey, next)
+	if err := app.proxmox.SetVMConfig(vmType, vmid, values); err != nil {
+		http.Error(w, fmt.Sprintf("Proxmox API error: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	network := app.nicNetwork(spec.Bridge, spec.Tag)
+	if old := parseNICSpec(vmType, cur); cur == "" || app.nicNetwork(old.Bridge, old.Tag) != network {
+		if err := app.autoReserveNIC(vmType, vmid, vmCfg["name"], vmCfg["hostname"], netKey, network); err != nil {
+			log.Printf("WARN: auto-reserve %s/%d %s: %v", vmType, vmid, netKey, err)
+		}
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// HandleVMNICDelete removes a NIC from a guest. DHCP reservations for its MAC
+// are kept.
+func (app *App) HandleVMNICDelete(w http.ResponseWriter, r *http.Request) {
+	vmType, vmid, netKey, err := vmNICParams(r)
+	if err != nil || netKey == "" {
+		http.Error(w, "Invalid NIC", http.StatusBadRequest)
+		return
+	}
+	values := url.Values{}
+	values.Set("delete", netKey)
+	if err := app.proxmox.SetVMConfig(vmType, vmid, values); err != nil {
+		http.Error(w, fmt.Sprintf("Proxmox API error: %v", err), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// autoReserveNIC pins an address for a NIC that was just moved onto a managed
+// bridge with auto_reserve enabled. A current lease on the bridge is kept;
+// otherwise the next free address outside the dynamic range is allocated.
+func (app *App) autoReserveNIC(vmType string, vmid int, name, hostname, netKey, bridge string) error {
+	app.cfg.Lock()
+	br := app.cfg.FindBridge(bridge)
+	enabled := br != nil && br.DHCP != nil && br.DHCP.AutoReserve
+	app.cfg.Unlock()
+	if !enabled {
+		return nil
+	}
+
+	// Re-read the config so QEMU NICs added without a MAC pick up the generated one.
+	vmCfg, err := app.proxmox.GetVMConfig(vmType, vmid)
+	if err != nil {
+		return err
+	}
+	var nic VMNICView
+	var ok bool
+	if vmType == "qemu" {
+		nic, ok = parseQemuNIC(netKey, vmCfg[netKey])
+	} else {
+		nic, ok = parseLXCNIC(netKey, vmCfg[netKey])
+		if ip := kvGet(splitCommaKV(vmCfg[netKey]), "ip"); ip != "" && ip != "dhcp" {
+			return nil // static address, DHCP not involved
+		}
+	}
+	if !ok || nic.MAC == "" {
+		return fmt.Errorf("NIC has no MAC address")
+	}
+	if hostname == "" {
+		hostname = name
+	}
+
+	leases, _ := app.dnsmasq.Leases()
+	vms, _ := app.proxmox.ListVMs()
+	vmViews := buildVMViews(app.proxmox, vms, leases)
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+	br = app.cfg.FindBridge(bridge)
+	if br == nil || br.DHCP == nil {
+		return nil
+	}
+	mac := normalizeMAC(nic.MAC)
+	for _, h := range br.DHCP.Hosts {
+		if normalizeMAC(h.MAC) == mac {
+			return nil // already pinned
+		}
+	}
+
+	ip := ""
+	for _, l := range leases {
+		if normalizeMAC(l.MAC) == mac && app.cfg.BridgeForIP(l.IP) == br {
+			ip = l.IP
+			break
+		}
+	}
+	if ip == "" {
+		ip, err = app.ipam.NextFree(br, buildUsedIPs(app.cfg, leases, vmViews), true)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := app.cfg.ReserveAddress(mac, ip, hostname); err != nil {
+		return err
+	}
+	log.Printf("auto-reserved %s for %s/%d %s (%s) on %s", ip, vmType, vmid, netKey, mac, br.Name)
+	if err := app.cfg.Save(); err != nil {
+		log.Printf("ERROR: save config: %v", err)
+	}
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply dnsmasq: %v", err)
+	}
+	return nil
+}
+
+// --- DHCP ---
+
+func (app *App) HandleDHCPList(w http.ResponseWriter, r *http.Request) {
+	leases, _ := app.dnsmasq.Leases()
+	vms, _ := app.proxmox.ListVMs()
+	vmViews := buildVMViews(app.proxmox, vms, leases)
+	action, applied := app.dnsmasq.LastApply()
+	filter := r.URL.Query().Get("bridge")
+
+	views := buildLeaseViews(app.cfg, leases, vmViews)
+	attachLeaseHistory(app.history, views)
+	var history []LeaseRecord
+	for _, rec := range app.history.Records() {
+		if filter == "" || rec.Bridge == filter {
+			history = append(history, rec)
+		}
+	}
+
+	app.render(w, "dhcp.html", map[string]any{
+		"Active":         "dhcp",
+		"Bridges":        app.cfg.Bridges,
+		"Leases":         filterLeaseViews(views, filter),
+		"History":        history,
+		"Filter":         filter,
+		"DNSMasqAction":  action,
+		"DNSMasqApplied": applied,
+	})
+}
+
+func (app *App) HandleDHCPForm(w http.ResponseWriter, r *http.Request) {
+	bridgeName := pathParam(r.URL.Path, "/dhcp/edit/")
+
+	br := app.cfg.FindBridge(bridgeName)
+	if br == nil {
+		http.Error(w, "Bridge not found", http.StatusNotFound)
+		return
+	}
+
+	data := map[string]any{
+		"Active":      "dhcp",
+		"BridgeName":  br.Name,
+		"GatewayIP":   br.GatewayIP,
+		"Enabled":     false,
+		"RangeStart":  "",
+		"RangeEnd":    "",
+		"Ranges":      "",
+		"Exclusions":  "",
+		"LeaseTime":   "12h",
+		"DNS1":        "1.1.1.1",
+		"DNS2":        "8.8.8.8",
+		"Subnet":      br.Subnet,
+		"Hosts":       []DHCPHost(nil),
+		"Options":     []DHCPOption(nil),
+		"OptionNames": dhcpOptionNames(),
+		"OptionSpecs": dhcpOptionSpecs,
+		"AutoReserve": false,
+		"Boot":        DHCPBoot{},
+		"IPv6":        IPv6Config{},
+		"IPv6Modes":   ipv6Modes,
+		"IPv6Labels":  ipv6ModeLabels,
+	}
+
+	if br.IPv6 != nil {
+		data["IPv6"] = *br.IPv6
+	} else if prefix := app.bridgeIPv6Prefix(br.Name); prefix != "" {
+		data["IPv6"] = IPv6Config{Prefix: prefix}
+	}
+
+	if br.DHCP != nil {
+		data["Enabled"] = true
+		data["RangeStart"] = br.DHCP.RangeStart
+		data["RangeEnd"] = br.DHCP.RangeEnd
+		data["Ranges"] = formatDHCPRanges(br.DHCP.Ranges)
+		data["Exclusions"] = formatDHCPExclusions(br.DHCP.Exclusions)
+		data["ReservedExclusions"] = reservationExclusions(br.DHCP.Exclusions)
+		data["LeaseTime"] = br.DHCP.LeaseTime
+		data["DNS1"] = br.DHCP.DNS1
+		data["DNS2"] = br.DHCP.DNS2
+		data["Hosts"] = br.DHCP.Hosts
+		data["Options"] = br.DHCP.Options
+		data["AutoReserve"] = br.DHCP.AutoReserve
+		if br.DHCP.Boot != nil {
+			data["Boot"] = *br.DHCP.Boot
+		}
+		if ip, err := app.ipam.NextFree(br, app.usedIPs(), false); err == nil {
+			data["NextFree"] = ip
+		}
+	}
+
+	app.render(w, "dhcp_form.html", data)
+}
+
+// bridgeIPv6Prefix returns the IPv6 prefix configured on a bridge in Proxmox, if any.
+func (app *App) bridgeIPv6Prefix(name string) string {
+	nets, err := app.proxmox.ListNetworks()
+	if err != nil {
+		return ""
+	}
+	for _, n := range nets {
+		if n.Iface != name || n.CIDR6 == "" {
+			continue
+		}
+		if prefix, err := parseCIDRv6(n.CIDR6); err == nil {
+			return prefix.String()
+		}
+	}
+	return ""
+}
+
+func (app *App) HandleDHCPSave(w http.ResponseWriter, r *http.Request) {
+	bridgeName := pathParam(r.URL.Path, "/dhcp/edit/")
+	enabled := r.FormValue("enabled") == "1"
+	rangeStart := r.FormValue("range_start")
+	rangeEnd := r.FormValue("range_end")
+	leaseTime := r.FormValue("lease_time")
+	dns1 := r.FormValue("dns1")
+	dns2 := r.FormValue("dns2")
+	autoReserve := r.FormValue("auto_reserve") == "1"
+	extraRanges, err := parseDHCPRanges(r.FormValue("ranges"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	exclusions, err := parseDHCPExclusions(r.FormValue("exclusions"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	boot := &DHCPBoot{
+		Filename:     strings.TrimSpace(r.FormValue("boot_filename")),
+		UEFIFilename: strings.TrimSpace(r.FormValue("boot_uefi_filename")),
+		NextServer:   strings.TrimSpace(r.FormValue("boot_next_server")),
+		TFTPRoot:     strings.TrimSpace(r.FormValue("boot_tftp_root")),
+	}
+	if *boot == (DHCPBoot{}) {
+		boot = nil
+	}
+	var v6 *IPv6Config
+	if mode := r.FormValue("ipv6_mode"); mode != "" {
+		v6 = &IPv6Config{
+			Prefix:    strings.TrimSpace(r.FormValue("ipv6_prefix")),
+			Mode:      mode,
+			LeaseTime: strings.TrimSpace(r.FormValue("ipv6_lease_time")),
+		}
+		if mode == "stateful" {
+			v6.RangeStart = strings.TrimSpace(r.FormValue("ipv6_range_start"))
+			v6.RangeEnd = strings.TrimSpace(r.FormValue("ipv6_range_end"))
+		}
+		if err := validateIPv6Config(v6); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	var static map[string]string
+	if enabled {
+		vms, _ := app.proxmox.ListVMs()
+		static = staticGuestIPs(buildVMViews(app.proxmox, vms, nil), bridgeName)
+	}
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+
+	br := app.cfg.FindBridge(bridgeName)
+	if br == nil {
+		http.Error(w, "Bridge not found", http.StatusNotFound)
+		return
+	}
+
+	if !enabled {
+		br.DHCP = nil
+	} else {
+		if dns1 != "" {
+			if _, err := parseIPv4(dns1); err != nil {
+				http.Error(w, "Invalid DNS1 IP", http.StatusBadRequest)
+				return
+			}
+		}
+		if dns2 != "" {
+			if _, err := parseIPv4(dns2); err != nil {
+				http.Error(w, "Invalid DNS2 IP", http.StatusBadRequest)
+				return
+			}
+		}
+		if leaseTime == "" {
+			leaseTime = "12h"
+		}
+
+		var hosts []DHCPHost
+		var options []DHCPOption
+		if br.DHCP != nil {
+			hosts = br.DHCP.Hosts
+			options = br.DHCP.Options
+		}
+		if boot != nil {
+			if err := validateDHCPBoot(boot, options); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		dhcp := &DHCPConfig{
+			RangeStart:  rangeStart,
+			RangeEnd:    rangeEnd,
+			Ranges:      extraRanges,
+			Exclusions:  exclusions,
+			LeaseTime:   leaseTime,
+			DNS1:        dns1,
+			DNS2:        dns2,
+			Hosts:       hosts,
+			Options:     options,
+			AutoReserve: autoReserve,
+			Boot:        boot,
+		}
+		dhcp.syncAllReservationExclusions()
+		if err := validateDHCPPools(br.Subnet, br.GatewayIP, dhcp, static); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		br.DHCP = dhcp
+	}
+	br.IPv6 = v6
+
+	if err := app.cfg.Save(); err != nil {
+		log.Printf("ERROR: save config: %v", err)
+	}
+	if err := app.nft.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply nftables: %v", err)
+	}
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply dnsmasq: %v", err)
+	}
+
+	http.Redirect(w, r, "/dhcp", http.StatusSeeOther)
+}
+
+func (app *App) HandleDHCPHostSave(w http.ResponseWriter, r *http.Request) {
+	bridgeName := strings.TrimSpace(r.FormValue("bridge"))
+	host := DHCPHost{
+		MAC:       normalizeMAC(r.FormValue("mac")),
+		IP:        strings.TrimSpace(r.FormValue("ip")),
+		Hostname:  strings.TrimSpace(r.FormValue("hostname")),
+		LeaseTime: strings.TrimSpace(r.FormValue("lease_time")),
+	}
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+
+	br := app.cfg.FindBridge(bridgeName)
+	if br == nil {
+		http.Error(w, "Bridge not found", http.StatusNotFound)
+		return
+	}
+	if err := br.SetDHCPHost(host); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	app.ipam.Release(host.IP)
+
+	if err := app.cfg.Save(); err != nil {
+		log.Printf("ERROR: save config: %v", err)
+	}
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply dnsmasq: %v", err)
+	}
+
+	http.Redirect(w, r, "/dhcp/edit/"+br.Name, http.StatusSeeOther)
+}
+
+func (app *App) HandleDHCPHostDelete(w http.ResponseWriter, r *http.Request) {
+	bridgeName := strings.TrimSpace(r.FormValue("bridge"))
+	mac := r.FormValue("mac")
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+
+	br := app.cfg.FindBridge(bridgeName)
+	if br == nil {
+		http.Error(w, "Bridge not found", http.StatusNotFound)
+		return
+	}
+	if !br.DeleteDHCPHost(mac) {
+		http.Error(w, "Reservation not found", http.StatusBadRequest)
+		return
+	}
+
+	if err := app.cfg.Save(); err != nil {
+		log.Printf("ERROR: save config: %v", err)
+	}
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply dnsmasq: %v", err)
+	}
+
+	http.Redirect(w, r, "/dhcp/edit/"+br.Name, http.StatusSeeOther)
+}
+
+func (app *App) HandleDHCPOptionAdd(w http.ResponseWriter, r *http.Request) {
+	bridgeName := strings.TrimSpace(r.FormValue("bridge"))
+	opt := DHCPOption{
+		ID:    generateID(),
+		Name:  strings.TrimSpace(r.FormValue("name")),
+		Value: normalizeOptionValue(r.FormValue("value")),
+	}
+	if opt.Name == "raw" {
+		opt.Name = ""
+		code, err := strconv.Atoi(strings.TrimSpace(r.FormValue("code")))
+		if err != nil {
+			http.Error(w, "Invalid option code", http.StatusBadRequest)
+			return
+		}
+		opt.Code = code
+	}
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+
+	br := app.cfg.FindBridge(bridgeName)
+	if br == nil {
+		http.Error(w, "Bridge not found", http.StatusNotFound)
+		return
+	}
+	if br.DHCP == nil {
+		http.Error(w, "DHCP is not enabled on this bridge", http.StatusBadRequest)
+		return
+	}
+	options := append(append([]DHCPOption(nil), br.DHCP.Options...), opt)
+	if err := validateDHCPOptions(options); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if br.DHCP.Boot != nil {
+		if err := validateDHCPBoot(br.DHCP.Boot, options); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	br.DHCP.Options = options
+
+	if err := app.cfg.Save(); err != nil {
+		log.Printf("ERROR: save config: %v", err)
+	}
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply dnsmasq: %v", err)
+	}
+
+	http.Redirect(w, r, "/dhcp/edit/"+br.Name, http.StatusSeeOther)
+}
+
+func (app *App) HandleDHCPOptionDelete(w http.ResponseWriter, r *http.Request) {
+	bridgeName := strings.TrimSpace(r.FormValue("bridge"))
+	id := r.FormValue("id")
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+
+	br := app.cfg.FindBridge(bridgeName)
+	if br == nil {
+		http.Error(w, "Bridge not found", http.StatusNotFound)
+		return
+	}
+	if !br.DeleteDHCPOption(id) {
+		http.Error(w, "Option not found", http.StatusBadRequest)
+		return
+	}
+
+	if err := app.cfg.Save(); err != nil {
+		log.Printf("ERROR: save config: %v", err)
+	}
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply dnsmasq: %v", err)
+	}
+
+	http.Redirect(w, r, "/dhcp/edit/"+br.Name, http.StatusSeeOther)
+}
+
+// HandleLeasePin turns a current lease into a permanent reservation for its MAC.
+func (app *App) HandleLeasePin(w http.ResponseWriter, r *http.Request) {
+	mac := strings.TrimSpace(r.FormValue("mac"))
+	ip := strings.TrimSpace(r.FormValue("ip"))
+	back := r.FormValue("return")
+	if back != "/" {
+		back = "/dhcp"
+	}
+
+	var hostname string
 	leases, _ := app.dnsmasq.Leases()
+	found := false
+	for _, l := range leases {
+		if normalizeMAC(l.MAC) == normalizeMAC(mac) && l.IP == ip {
+			hostname = l.Hostname
+			found = true
+			break
+		}
+	}
+	if !found {
+		http.Error(w, "Lease not found", http.StatusBadRequest)
+		return
+	}
 
-	app.render(w, "dhcp.html", map[string]any{
-		"Active":  "dhcp",
-		"Bridges": app.cfg.Bridges,
-		"Leases":  leases,
-	})
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+
+	if _, err := app.cfg.ReserveAddress(mac, ip, hostname); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := app.cfg.Save(); err != nil {
+		log.Printf("ERROR: save config: %v", err)
+	}
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply dnsmasq: %v", err)
+	}
+
+	http.Redirect(w, r, back, http.StatusSeeOther)
 }
 
-func (app *App) HandleDHCPForm(w http.ResponseWriter, r *http.Request) {
-	bridgeName := pathParam(r.URL.Path, "/dhcp/edit/")
+// HandleLeaseRelease removes a lease from dnsmasq so its address is free again.
+func (app *App) HandleLeaseRelease(w http.ResponseWriter, r *http.Request) {
+	ip := strings.TrimSpace(r.FormValue("ip"))
+	mac := strings.TrimSpace(r.FormValue("mac"))
+	back := "/dhcp"
+	if bridge := r.FormValue("bridge"); bridge != "" {
+		back += "?bridge=" + url.QueryEscape(bridge)
+	}
+
+	if _, err := releaseLease(app.cfg, app.dnsmasq, app.history, ip, mac); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// --- DNS ---
+
+func (app *App) HandleDNSForm(w http.ResponseWriter, r *http.Request) {
+	bridgeName := pathParam(r.URL.Path, "/dns/edit/")
 
 	br := app.cfg.FindBridge(bridgeName)
 	if br == nil {
@@ -783,33 +2072,33 @@ func (app *App) HandleDHCPForm(w http.ResponseWriter, r *http.Request) {
 		"BridgeName": br.Name,
 		"GatewayIP":  br.GatewayIP,
 		"Enabled":    false,
-		"RangeStart": "",
-		"RangeEnd":   "",
-		"LeaseTime":  "12h",
-		"DNS1":       "1.1.1.1",
-		"DNS2":       "8.8.8.8",
+		"Domain":     "lan",
+		"Upstreams":  "1.1.1.1, 8.8.8.8",
+		"VMNames":    true,
+		"Records":    []DNSRecord(nil),
 	}
-
-	if br.DHCP != nil {
+	if br.DNS != nil {
 		data["Enabled"] = true
-		data["RangeStart"] = br.DHCP.RangeStart
-		data["RangeEnd"] = br.DHCP.RangeEnd
-		data["LeaseTime"] = br.DHCP.LeaseTime
-		data["DNS1"] = br.DHCP.DNS1
-		data["DNS2"] = br.DHCP.DNS2
+		data["Domain"] = br.DNS.Domain
+		data["Upstreams"] = strings.Join(br.DNS.Upstreams, ", ")
+		data["VMNames"] = br.DNS.VMNames
+		data["Records"] = br.DNS.Records
 	}
 
-	app.render(w, "dhcp_form.html", data)
+	app.render(w, "dns_form.html", data)
 }
 
-func (app *App) HandleDHCPSave(w http.ResponseWriter, r *http.Request) {
-	bridgeName := pathParam(r.URL.Path, "/dhcp/edit/")
+func (app *App) HandleDNSSave(w http.ResponseWriter, r *http.Request) {
+	bridgeName := pathParam(r.URL.Path, "/dns/edit/")
 	enabled := r.FormValue("enabled") == "1"
-	rangeStart := r.FormValue("range_start")
-	rangeEnd := r.FormValue("range_end")
-	leaseTime := r.FormValue("lease_time")
-	dns1 := r.FormValue("dns1")
-	dns2 := r.FormValue("dns2")
+	domain := strings.ToLower(strings.Trim(strings.TrimSpace(r.FormValue("domain")), "."))
+	vmNames := r.FormValue("vm_names") == "1"
+	var upstreams []string
+	for _, u := range strings.Split(r.FormValue("upstreams"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			upstreams = append(upstreams, u)
+		}
+	}
 
 	app.cfg.Lock()
 	defer app.cfg.Unlock()
@@ -821,45 +2110,170 @@ func (app *App) HandleDHCPSave(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if !enabled {
-		br.DHCP = nil
+		br.DNS = nil
 	} else {
-		if err := validateDHCPRange(br.Subnet, br.GatewayIP, rangeStart, rangeEnd); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		if dns1 != "" {
-			if _, err := parseIPv4(dns1); err != nil {
-				http.Error(w, "Invalid DNS1 IP", http.StatusBadRequest)
-				return
-			}
+		var records []DNSRecord
+		if br.DNS != nil {
+			records = br.DNS.Records
 		}
-		if dns2 != "" {
-			if _, err := parseIPv4(dns2); err != nil {
-				http.Error(w, "Invalid DNS2 IP", http.StatusBadRequest)
-				return
-			}
+		dns := &DNSConfig{
+			Domain:    domain,
+			Upstreams: upstreams,
+			VMNames:   vmNames,
+			Records:   records,
 		}
-		if leaseTime == "" {
-			leaseTime = "12h"
+		if err := validateDNSConfig(dns); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		br.DNS = dns
+	}
 
-		br.DHCP = &DHCPConfig{
-			RangeStart: rangeStart,
-			RangeEnd:   rangeEnd,
-			LeaseTime:  leaseTime,
-			DNS1:       dns1,
-			DNS2:       dns2,
+	if err := app.cfg.Save(); err != nil {
+		log.Printf("ERROR: save config: %v", err)
+	}
+	if err := app.nft.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply nftables: %v", err)
+	}
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply dnsmasq: %v", err)
+	}
+	go app.syncDNSHosts()
+
+	http.Redirect(w, r, "/dns/edit/"+br.Name, http.StatusSeeOther)
+}
+
+func (app *App) HandleDNSRecordAdd(w http.ResponseWriter, r *http.Request) {
+	bridgeName := strings.TrimSpace(r.FormValue("bridge"))
+	rec := DNSRecord{
+		Name:  strings.ToLower(strings.TrimSpace(r.FormValue("name"))),
+		Type:  strings.ToUpper(strings.TrimSpace(r.FormValue("type"))),
+		Value: strings.ToLower(strings.TrimSpace(r.FormValue("value"))),
+	}
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+
+	br := app.cfg.FindBridge(bridgeName)
+	if br == nil {
+		http.Error(w, "Bridge not found", http.StatusNotFound)
+		return
+	}
+	if br.DNS == nil {
+		http.Error(w, "DNS is not enabled on this bridge", http.StatusBadRequest)
+		return
+	}
+	next := *br.DNS
+	next.Records = append(append([]DNSRecord(nil), br.DNS.Records...), rec)
+	if err := validateDNSConfig(&next); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	br.DNS.Records = next.Records
+
+	if err := app.cfg.Save(); err != nil {
+		log.Printf("ERROR: save config: %v", err)
+	}
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
+		log.Printf("ERROR: apply dnsmasq: %v", err)
+	}
+
+	http.Redirect(w, r, "/dns/edit/"+br.Name, http.StatusSeeOther)
+}
+
+func (app *App) HandleDNSRecordDelete(w http.ResponseWriter, r *http.Request) {
+	bridgeName := strings.TrimSpace(r.FormValue("bridge"))
+	name := r.FormValue("name")
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+
+	br := app.cfg.FindBridge(bridgeName)
+	if br == nil || br.DNS == nil {
+		http.Error(w, "Bridge not found", http.StatusNotFound)
+		return
+	}
+	found := false
+	for i, rec := range br.DNS.Records {
+		if rec.Name == name {
+			br.DNS.Records = append(br.DNS.Records[:i], br.DNS.Records[i+1:]...)
+			found = true
+			break
 		}
 	}
+	if !found {
+		http.Error(w, "Record not found", http.StatusBadRequest)
+		return
+	}
 
 	if err := app.cfg.Save(); err != nil {
 		log.Printf("ERROR: save config: %v", err)
 	}
-	if err := app.dnsmasq.Apply(app.cfg); err != nil {
+	if _, err := app.dnsmasq.Apply(app.cfg); err != nil {
 		log.Printf("ERROR: apply dnsmasq: %v", err)
 	}
 
-	http.Redirect(w, r, "/dhcp", http.StatusSeeOther)
+	http.Redirect(w, r, "/dns/edit/"+br.Name, http.StatusSeeOther)
+}
+
+// scanConflicts looks for IP addresses claimed by more than one client.
+func (app *App) scanConflicts() {
+	leases, err := app.dnsmasq.Leases()
+	if err != nil {
+		log.Printf("WARN: conflict scan: %v", err)
+	}
+	vms, err := app.proxmox.ListVMs()
+	if err != nil {
+		log.Printf("WARN: conflict scan: %v", err)
+	}
+	app.conflicts.Scan(app.cfg, leases, buildVMViews(app.proxmox, vms, leases))
+}
+
+// recordLeaseHistory merges the current dnsmasq leases into the lease history.
+func (app *App) recordLeaseHistory() {
+	leases, err := app.dnsmasq.Leases()
+	if err != nil {
+		log.Printf("WARN: lease history: %v", err)
+		return
+	}
+	app.cfg.Lock()
+	views := buildLeaseViews(app.cfg, leases, nil)
+	app.cfg.Unlock()
+	if err := app.history.Observe(views); err != nil {
+		log.Printf("WARN: lease history: %v", err)
+	}
+}
+
+// syncDNSHosts publishes Proxmox guest names on bridges with vm_names enabled.
+func (app *App) syncDNSHosts() {
+	// Proxmox and the leases are read without holding the config lock.
+	app.cfg.Lock()
+	cfg := dnsHostsConfig(app.cfg)
+	app.cfg.Unlock()
+
+	publish := false
+	for _, b := range cfg.Bridges {
+		if b.DNS != nil && b.DNS.VMNames {
+			publish = true
+			break
+		}
+	}
+	var lines []string
+	if publish {
+		leases, _ := app.dnsmasq.Leases()
+		vms, err := app.proxmox.ListVMs()
+		if err != nil {
+			log.Printf("WARN: DNS host sync: %v", err)
+			return
+		}
+		lines = buildDNSHostLines(cfg, buildVMViews(app.proxmox, vms, leases))
+	}
+	if !hasDNS(cfg) {
+		return
+	}
+	if err := app.dnsmasq.UpdateHosts(lines); err != nil {
+		log.Printf("WARN: DNS host sync: %v", err)
+	}
 }
 
 // --- API endpoints (JSON) ---
@@ -873,6 +2287,84 @@ func (app *App) HandleAPIVMs(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, vms)
 }
 
+// HandleAPIInventory returns the state of the Proxmox inventory cache.
+func (app *App) HandleAPIInventory(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, app.proxmox.Health())
+}
+
+// HandleAPINetwork returns the pending network changes and whether applied
+// ones await confirmation.
+func (app *App) HandleAPINetwork(w http.ResponseWriter, r *http.Request) {
+	status, err := app.network.Pending(app.proxmox)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// HandleAPISDN returns the SDN zones and the VNets with their subnets.
+func (app *App) HandleAPISDN(w http.ResponseWriter, r *http.Request) {
+	zones, err := app.proxmox.ListSDNZones()
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	vnets, err := app.proxmox.ListSDNVNets()
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	app.cfg.Lock()
+	managed := map[string]bool{}
+	for _, b := range app.cfg.Bridges {
+		managed[b.Name] = true
+	}
+	app.cfg.Unlock()
+
+	type vnetView struct {
+		SDNVNet
+		Managed bool        `json:"managed"`
+		Subnets []SDNSubnet `json:"subnets"`
+	}
+	out := make([]vnetView, 0, len(vnets))
+	for _, v := range vnets {
+		subnets, _ := app.proxmox.ListSDNSubnets(v.VNet)
+		out = append(out, vnetView{SDNVNet: v, Managed: managed[v.VNet], Subnets: subnets})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"zones": zones, "vnets": out})
+}
+
+// HandleAPICluster returns the cluster nodes, guests per node, forwards whose
+// target migrated away and the managed bridges on each bridge node.
+func (app *App) HandleAPICluster(w http.ResponseWriter, r *http.Request) {
+	nodes, err := app.proxmox.ListNodes()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	leases, _ := app.dnsmasq.Leases()
+	vms, _ := app.proxmox.ListVMs()
+	vmViews := buildVMViews(app.proxmox, vms, leases)
+
+	app.cfg.Lock()
+	migrated := migratedForwards(app.cfg, vmViews)
+	local, bridgeNodes, bridgeNames := app.cfg.ProxmoxNode, app.cfg.BridgeNodes, app.cfg.bridgeNames()
+	app.cfg.Unlock()
+
+	guests := map[string]int{}
+	for _, vm := range vms {
+		guests[vm.Node]++
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"node":              local,
+		"nodes":             nodes,
+		"guests":            guests,
+		"migrated_forwards": migrated,
+		"bridge_nodes":      clusterBridges(app.proxmox, bridgeNodes, bridgeNames),
+	})
+}
+
 func (app *App) HandleAPINFTStatus(w http.ResponseWriter, r *http.Request) {
 	status, err := app.nft.Status()
 	if err != nil {
@@ -882,13 +2374,139 @@ func (app *App) HandleAPINFTStatus(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"rules": status})
 }
 
+// HandleAPIDHCPLeases returns current leases with bridge, expiry and history;
+// ?bridge=<name> limits the result to one bridge.
 func (app *App) HandleAPIDHCPLeases(w http.ResponseWriter, r *http.Request) {
 	leases, err := app.dnsmasq.Leases()
 	if err != nil {
 		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	writeJSON(w, http.StatusOK, leases)
+	views := buildLeaseViews(app.cfg, leases, nil)
+	attachLeaseHistory(app.history, views)
+	writeJSON(w, http.StatusOK, filterLeaseViews(views, r.URL.Query().Get("bridge")))
+}
+
+// HandleAPILeaseRelease releases the lease for form value ip (and mac, if given).
+func (app *App) HandleAPILeaseRelease(w http.ResponseWriter, r *http.Request) {
+	ip := strings.TrimSpace(r.FormValue("ip"))
+	via, err := releaseLease(app.cfg, app.dnsmasq, app.history, ip, strings.TrimSpace(r.FormValue("mac")))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"released": ip, "via": via})
+}
+
+// HandleAPIConflicts returns the IP conflicts found by the last background
+// scan; ?bridge=<name> limits the result to one bridge.
+func (app *App) HandleAPIConflicts(w http.ResponseWriter, r *http.Request) {
+	conflicts, scanned, arping := app.conflicts.Results()
+	bridge := r.URL.Query().Get("bridge")
+	out := make([]IPConflict, 0, len(conflicts))
+	for _, c := range conflicts {
+		if bridge == "" || c.Bridge == bridge {
+			out = append(out, c)
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"scanned":   scanned,
+		"arp_probe": arping,
+		"conflicts": out,
+	})
+}
+
+// usedIPs collects the addresses in use on the managed bridges.
+func (app *App) usedIPs() []BridgeUsedIPs {
+	leases, _ := app.dnsmasq.Leases()
+	vms, _ := app.proxmox.ListVMs()
+	return buildUsedIPs(app.cfg, leases, buildVMViews(app.proxmox, vms, leases))
+}
+
+// HandleAPIRenumberPreview returns the renumbering plan for ?bridge= and
+// ?subnet= without applying it.
+func (app *App) HandleAPIRenumberPreview(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("bridge")
+	static, views := app.renumberInputs(name)
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+	plan, err := app.planRenumber(name, strings.TrimSpace(r.URL.Query().Get("subnet")), static, views)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, plan)
+}
+
+// HandleAPISubnetNext suggests a free subnet from the subnet pool for a new
+// bridge, with gateway and default DHCP range.
+func (app *App) HandleAPISubnetNext(w http.ResponseWriter, r *http.Request) {
+	extra := unmanagedBridgeCIDRs(app.buildBridgeViews())
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+	s, err := app.cfg.NextFreeSubnet(extra)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, s)
+}
+
+// HandleAPIIPAMNext returns the next free address outside the DHCP ranges of
+// ?bridge=<name> without holding it.
+func (app *App) HandleAPIIPAMNext(w http.ResponseWriter, r *http.Request) {
+	used := app.usedIPs()
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+	br := app.cfg.FindBridge(r.URL.Query().Get("bridge"))
+	if br == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "bridge not found"})
+		return
+	}
+	ip, err := app.ipam.NextFree(br, used, false)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"bridge": br.Name, "ip": ip})
+}
+
+// HandleAPIIPAMHold allocates the next free address on form value bridge and
+// holds it for a few minutes; form value note describes the caller.
+func (app *App) HandleAPIIPAMHold(w http.ResponseWriter, r *http.Request) {
+	used := app.usedIPs()
+
+	app.cfg.Lock()
+	defer app.cfg.Unlock()
+	br := app.cfg.FindBridge(strings.TrimSpace(r.FormValue("bridge")))
+	if br == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "bridge not found"})
+		return
+	}
+	hold, err := app.ipam.Hold(br, used, strings.TrimSpace(r.FormValue("note")))
+	if err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("ipam: holding %s on %s until %s", hold.IP, hold.Bridge, hold.Expires.Format(time.RFC3339))
+	writeJSON(w, http.StatusOK, hold)
+}
+
+func (app *App) HandleAPIIPAMHolds(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, app.ipam.Holds())
+}
+
+// HandleAPIIPAMRelease drops the hold on form value ip.
+func (app *App) HandleAPIIPAMRelease(w http.ResponseWriter, r *http.Request) {
+	ip := strings.TrimSpace(r.FormValue("ip"))
+	if !app.ipam.Release(ip) {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no hold for " + ip})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"released": ip})
 }
 
 func writeJSON(w http.ResponseWriter, status int, v any) {
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultInventoryTTL is how long Proxmox read responses are reused
+	// when inventory_ttl is not set.
+	defaultInventoryTTL = 15 * time.Second
+	// readTimeout bounds a single Proxmox GET; writes keep the client timeout.
+	readTimeout = 5 * time.Second
+	// retryDownAfter is how long cached data is served without retrying once
+	// Proxmox stopped answering.
+	retryDownAfter = 15 * time.Second
+	// inventoryWorkers bounds the parallel guest config requests.
+	inventoryWorkers = 8
+)
+
+// cachedGet is a cached GET response. err is an error Proxmox answered with
+// (e.g. 403); it is cached like a body so it is not retried on every page.
+type cachedGet struct {
+	body    []byte
+	err     error
+	fetched time.Time
+}
+
+// inventoryCache holds GET responses of the serve process and the state of the
+// connection to Proxmox. A zero ttl disables caching.
+type inventoryCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	entries  map[string]cachedGet
+	lastOK   time.Time // last request Proxmox answered
+	downAt   time.Time // first failure since lastOK; zero while Proxmox answers
+	failedAt time.Time // last failure
+	downErr  error
+}
+
+// ProxmoxHealth tells pages whether the inventory they show is current.
+type ProxmoxHealth struct {
+	Stale bool      `json:"stale"`             // Proxmox is not answering; cached data is shown
+	Since time.Time `json:"last_ok,omitempty"` // when Proxmox last answered; zero if never
+	Err   string    `json:"error,omitempty"`
+}
+
+// EnableCache makes the client reuse GET responses for ttl. Any write through
+// the client drops the cache, so the next page shows the result.
+func (p *ProxmoxClient) EnableCache(ttl time.Duration) {
+	p.inv.mu.Lock()
+	defer p.inv.mu.Unlock()
+	p.inv.ttl = ttl
+	p.inv.entries = map[string]cachedGet{}
+}
+
+// Invalidate drops all cached responses.
+func (p *ProxmoxClient) Invalidate() {
+	p.inv.mu.Lock()
+	defer p.inv.mu.Unlock()
+	if p.inv.entries != nil {
+		p.inv.entries = map[string]cachedGet{}
+	}
+}
+
+// forget drops the cached response for path.
+func (p *ProxmoxClient) forget(path string) {
+	p.inv.mu.Lock()
+	defer p.inv.mu.Unlock()
+	delete(p.inv.entries, path)
+}
+
+// Health reports whether Proxmox is currently answering.
+func (p *ProxmoxClient) Health() ProxmoxHealth {
+	p.inv.mu.Lock()
+	defer p.inv.mu.Unlock()
+	h := ProxmoxHealth{Since: p.inv.lastOK}
+	if !p.inv.downAt.IsZero() {
+		h.Stale = true
+		h.Err = p.inv.downErr.Error()
+	}
+	return h
+}
+
+// lookup returns the cached response for path if it is fresh, or any cached
+// response while Proxmox is down. ok is false when path has to be fetched.
+// While Proxmox is down and nothing is cached the last failure is returned,
+// so pages fail fast rather than waiting for a timeout per request.
+func (p *ProxmoxClient) lookup(path string) (c cachedGet, ok bool) {
+	p.inv.mu.Lock()
+	defer p.inv.mu.Unlock()
+	if p.inv.ttl == 0 {
+		return cachedGet{}, false
+	}
+	down := !p.inv.failedAt.IsZero() && time.Since(p.inv.failedAt) < retryDownAfter
+	c, cached := p.inv.entries[path]
+	switch {
+	case cached && (time.Since(c.fetched) < p.inv.ttl || down):
+		return c, true
+	case down:
+		return cachedGet{err: p.inv.downErr}, true
+	}
+	return cachedGet{}, false
+}
+
+// store caches a response Proxmox answered.
+func (p *ProxmoxClient) store(path string, body []byte, err error) {
+	p.inv.mu.Lock()
+	defer p.inv.mu.Unlock()
+	if p.inv.ttl == 0 {
+		return
+	}
+	p.inv.entries[path] = cachedGet{body: body, err: err, fetched: time.Now()}
+}
+
+// stale returns any cached response for path after a failed request.
+func (p *ProxmoxClient) stale(path string) (cachedGet, bool) {
+	p.inv.mu.Lock()
+	defer p.inv.mu.Unlock()
+	c, ok := p.inv.entries[path]
+	return c, ok
+}
+
+// noteResult records whether Proxmox answered a request.
+func (p *ProxmoxClient) noteResult(answered bool, err error) {
+	p.inv.mu.Lock()
+	defer p.inv.mu.Unlock()
+	if answered {
+		p.inv.lastOK = time.Now()
+		p.inv.downAt = time.Time{}
+		p.inv.failedAt = time.Time{}
+		p.inv.downErr = nil
+		return
+	}
+	p.inv.failedAt = time.Now()
+	if p.inv.downAt.IsZero() {
+		p.inv.downAt = p.inv.failedAt
+	}
+	p.inv.downErr = err
+}
+
+// guestDetails is what buildVMViews needs per guest beyond the VM list.
+type guestDetails struct {
+	config   map[string]string
+	agentIPs map[string][]string // normalized MAC -> "ip/prefix"
+	err      error               // config could not be read
+}
+
+// fetchGuestDetails reads the configs of vms, and the guest agent addresses of
+// running VMs with the agent enabled, with at most inventoryWorkers guests
+// fetched at a time. A guest whose config cannot be read gets a nil config.
+func fetchGuestDetails(px *ProxmoxClient, vms []VM) []guestDetails {
+	out := make([]guestDetails, len(vms))
+	sem := make(chan struct{}, inventoryWorkers)
+	var wg sync.WaitGroup
+	for i, vm := range vms {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			cfg, err := px.GetVMConfig(vm.Type, vm.VMID)
+			if err != nil {
+				log.Printf("WARN: failed to get VM config %s/%d: %v", vm.Type, vm.VMID, err)
+			}
+			out[i].config, out[i].err = cfg, err
+			if vm.Type == "qemu" && vm.Status == "running" && agentEnabled(cfg) {
+				// An agent that is not running yet answers with an error; the
+				// VM then simply has no agent addresses.
+				out[i].agentIPs, _ = px.GuestAgentIPs(vm.VMID)
+			}
+		}()
+	}
+	wg.Wait()
+	return out
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// ipamHoldsPath keeps the holds, shared by the web server and the TUI.
+	ipamHoldsPath = "/var/lib/pnat/ipam-holds.json"
+	// ipamHoldTTL is how long a held address stays claimed without being used.
+	ipamHoldTTL = 5 * time.Minute
+)
+
+// IPHold is a short-lived claim on an address handed out by the IPAM.
+type IPHold struct {
+	Bridge  string    `json:"bridge"`
+	IP      string    `json:"ip"`
+	Note    string    `json:"note,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+// IPAM allocates free static addresses on managed bridges, based on the
+// addresses buildUsedIPs knows about plus reservations and exclusions. Holds
+// keep an address from being handed out twice; they are kept in a file so the
+// web server and the TUI see each other's.
+type IPAM struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewIPAM(path string) *IPAM {
+	return &IPAM{path: path}
+}
+
+// NextFree returns the lowest free address on br. Addresses in the dynamic
+// DHCP pools are only used when inPool is set and nothing outside is free.
+// The caller must hold the config lock.
+func (p *IPAM) NextFree(br *BridgeConfig, used []BridgeUsedIPs, inPool bool) (string, error) {
+	return p.next(br, used, inPool, p.load())
+}
+
+// Hold allocates the next free address outside the DHCP pools of br and
+// claims it for ipamHoldTTL. The caller must hold the config lock.
+func (p *IPAM) Hold(br *BridgeConfig, used []BridgeUsedIPs, note string) (IPHold, error) {
+	var h IPHold
+	err := p.update(func(holds map[string]IPHold) error {
+		ip, err := p.next(br, used, false, holds)
+		if err != nil {
+			return err
+		}
+		h = IPHold{Bridge: br.Name, IP: ip, Note: note, Expires: time.Now().Add(ipamHoldTTL)}
+		holds[ip] = h
+		return nil
+	})
+	return h, err
+}
+
+// Release drops the hold on ip. Returns true if there was one.
+func (p *IPAM) Release(ip string) bool {
+	ok := false
+	err := p.update(func(holds map[string]IPHold) error {
+		_, ok = holds[ip]
+		delete(holds, ip)
+		return nil
+	})
+	if err != nil {
+		log.Printf("ERROR: release %s: %v", ip, err)
+	}
+	return ok
+}
+
+// Holds returns the active holds, soonest to expire first.
+func (p *IPAM) Holds() []IPHold {
+	holds := p.load()
+	out := make([]IPHold, 0, len(holds))
+	for _, h := range holds {
+		out = append(out, h)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Expires.Before(out[j].Expires) })
+	return out
+}
+
+// load reads the holds that have not expired. The file is replaced
+// atomically, so reading it needs no lock.
+func (p *IPAM) load() map[string]IPHold {
+	holds := map[string]IPHold{}
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("WARN: read IPAM holds: %v", err)
+		}
+		return holds
+	}
+	var list []IPHold
+	if err := json.Unmarshal(data, &list); err != nil {
+		log.Printf("WARN: parse IPAM holds: %v", err)
+		return holds
+	}
+	now := time.Now()
+	for _, h := range list {
+		if now.Before(h.Expires) {
+			holds[h.IP] = h
+		}
+	}
+	return holds
+}
+
+// update runs fn on the current holds and saves them, under a lock so the web
+// server and the TUI do not hand out the same address.
+func (p *IPAM) update(fn func(holds map[string]IPHold) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	unlock, err := lockFile(p.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	holds := p.load()
+	if err := fn(holds); err != nil {
+		return err
+	}
+	list := make([]IPHold, 0, len(holds))
+	for _, h := range holds {
+		list = append(list, h)
+	}
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal IPAM holds: %w", err)
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write IPAM holds: %w", err)
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename IPAM holds: %w", err)
+	}
+	return nil
+}
+
+func (p *IPAM) next(br *BridgeConfig, used []BridgeUsedIPs, inPool bool, holds map[string]IPHold) (string, error) {
+	taken := map[string]bool{}
+	for _, b := range used {
+		if b.Bridge != br.Name {
+			continue
+		}
+		for _, u := range b.IPs {
+			taken[u.IP] = true
+		}
+	}
+	for _, f := range br.Forwards {
+		taken[f.IntIP] = true
+	}
+	if br.DHCP != nil {
+		for _, h := range br.DHCP.Hosts {
+			taken[h.IP] = true
+		}
+	}
+	for ip := range holds {
+		taken[ip] = true
+	}
+	ip, err := nextFreeIPv4(br.Subnet, br.GatewayIP, taken, br.DHCP)
+	if err != nil {
+		return "", err
+	}
+	if !inPool && br.DHCP != nil {
+		if _, ok := poolContaining(br.DHCP.pools(), ip); ok {
+			return "", fmt.Errorf("no free address outside the DHCP ranges of %s", br.Name)
+		}
+	}
+	return ip, nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	leaseHistoryPath    = "/var/lib/pnat/lease-history.json"
+	leaseHistoryMaxPrev = 10
+	// Only bump last-seen this often so polling does not rewrite the file constantly.
+	leaseHistoryTouchInterval = time.Minute
+	// Clients not seen for this long are dropped from the history.
+	leaseHistoryRetention = 90 * 24 * time.Hour
+)
+
+// LeaseHistory keeps first/last seen times and previous IPs of DHCP clients
+// across dnsmasq lease expiry, persisted as JSON.
+//
+// The web server records leases; the TUI only reads the history and records
+// releases. Both merge their records into the file under a lock when saving,
+// and pick up each other's changes when the file changes.
+type LeaseHistory struct {
+	path    string
+	mu      sync.Mutex
+	records map[string]*LeaseRecord
+	modTime time.Time // of the file when last read
+}
+
+// NewLeaseHistory loads the history file at path; a missing or broken file
+// starts an empty history.
+func NewLeaseHistory(path string) *LeaseHistory {
+	h := &LeaseHistory{path: path, records: map[string]*LeaseRecord{}}
+	h.reload()
+	return h
+}
+
+// readLeaseRecords reads the history file at path.
+func readLeaseRecords(path string) (map[string]*LeaseRecord, error) {
+	out := map[string]*LeaseRecord{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return out, nil
+		}
+		return nil, fmt.Errorf("read lease history: %w", err)
+	}
+	var records []LeaseRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("parse lease history: %w", err)
+	}
+	for i := range records {
+		r := records[i]
+		out[leaseRecordKey(r.MAC, r.IPv6)] = &r
+	}
+	return out, nil
+}
+
+// reload merges the file into the records if it changed since it was last
+// read. The caller must hold h.mu.
+func (h *LeaseHistory) reload() {
+	st, err := os.Stat(h.path)
+	if err != nil || st.ModTime().Equal(h.modTime) {
+		return
+	}
+	disk, err := readLeaseRecords(h.path)
+	if err != nil {
+		log.Printf("WARN: %v", err)
+		return
+	}
+	h.merge(disk)
+	h.modTime = st.ModTime()
+}
+
+// merge adds other records to h, keeping the more recent data of a client
+// both know.
+func (h *LeaseHistory) merge(other map[string]*LeaseRecord) {
+	for key, o := range other {
+		r, ok := h.records[key]
+		if !ok {
+			h.records[key] = o
+			continue
+		}
+		merged := *r
+		if o.LastSeen.After(r.LastSeen) {
+			merged = *o
+		}
+		if o.FirstSeen.Before(r.FirstSeen) {
+			merged.FirstSeen = o.FirstSeen
+		} else {
+			merged.FirstSeen = r.FirstSeen
+		}
+		if o.ReleasedAt.After(r.ReleasedAt) {
+			merged.ReleasedAt, merged.ReleasedIP, merged.ReleasedVia = o.ReleasedAt, o.ReleasedIP, o.ReleasedVia
+		} else {
+			merged.ReleasedAt, merged.ReleasedIP, merged.ReleasedVia = r.ReleasedAt, r.ReleasedIP, r.ReleasedVia
+		}
+		h.records[key] = &merged
+	}
+}
+
+// prune drops clients not seen within leaseHistoryRetention.
+func (h *LeaseHistory) prune(now time.Time) {
+	for key, r := range h.records {
+		if now.Sub(r.LastSeen) > leaseHistoryRetention && now.Sub(r.ReleasedAt) > leaseHistoryRetention {
+			delete(h.records, key)
+		}
+	}
+}
+
+func leaseRecordKey(mac string, ipv6 bool) string {
+	if ipv6 {
+		return normalizeMAC(mac) + "/6"
+	}
+	return normalizeMAC(mac)
+}
+
+// Observe merges the current leases into the history and saves it if anything changed.
+func (h *LeaseHistory) Observe(leases []LeaseView) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	changed := false
+	for _, l := range leases {
+		mac := normalizeMAC(l.MAC)
+		if mac == "" {
+			continue
+		}
+		key := leaseRecordKey(mac, l.IsIPv6())
+		r, ok := h.records[key]
+		if !ok {
+			h.records[key] = &LeaseRecord{
+				MAC:       mac,
+				IPv6:      l.IsIPv6(),
+				IP:        l.IP,
+				Hostname:  l.Hostname,
+				Bridge:    l.Bridge,
+				FirstSeen: now,
+				LastSeen:  now,
+			}
+			changed = true
+			continue
+		}
+		if r.IP != l.IP {
+			prev := []string{r.IP}
+			for _, ip := range r.PreviousIPs {
+				if ip != l.IP && ip != r.IP {
+					prev = append(prev, ip)
+				}
+			}
+			if len(prev) > leaseHistoryMaxPrev {
+				prev = prev[:leaseHistoryMaxPrev]
+			}
+			r.PreviousIPs = prev
+			r.IP = l.IP
+			changed = true
+		}
+		if l.Hostname != "" && r.Hostname != l.Hostname {
+			r.Hostname = l.Hostname
+			changed = true
+		}
+		if l.Bridge != "" && r.Bridge != l.Bridge {
+			r.Bridge = l.Bridge
+			changed = true
+		}
+		if now.Sub(r.LastSeen) >= leaseHistoryTouchInterval {
+			r.LastSeen = now
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return h.save()
+}
+
+// RecordRelease notes that the lease l was released manually.
+func (h *LeaseHistory) RecordRelease(l LeaseView, via string) error {
+	mac := normalizeMAC(l.MAC)
+	if mac == "" {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	key := leaseRecordKey(mac, l.IsIPv6())
+	r, ok := h.records[key]
+	if !ok {
+		r = &LeaseRecord{MAC: mac, IPv6: l.IsIPv6(), IP: l.IP, Hostname: l.Hostname, Bridge: l.Bridge, FirstSeen: now, LastSeen: now}
+		h.records[key] = r
+	}
+	r.ReleasedAt, r.ReleasedIP, r.ReleasedVia = now, l.IP, via
+	return h.save()
+}
+
+// Get returns the history of mac for the given address family.
+func (h *LeaseHistory) Get(mac string, ipv6 bool) (LeaseRecord, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.reload()
+	r, ok := h.records[leaseRecordKey(mac, ipv6)]
+	if !ok {
+		return LeaseRecord{}, false
+	}
+	return *r, true
+}
+
+// Records returns all records, most recently seen first.
+func (h *LeaseHistory) Records() []LeaseRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.reload()
+	out := make([]LeaseRecord, 0, len(h.records))
+	for _, r := range h.records {
+		out = append(out, *r)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+// save writes the history, merged with the file as it is now, under a lock so
+// that the web server and the TUI do not drop each other's records. The
+// caller must hold h.mu.
+func (h *LeaseHistory) save() error {
+	unlock, err := lockFile(h.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	disk, err := readLeaseRecords(h.path)
+	if err != nil {
+		return err
+	}
+	h.merge(disk)
+	h.prune(time.Now())
+
+	records := make([]LeaseRecord, 0, len(h.records))
+	for _, r := range h.records {
+		records = append(records, *r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].MAC < records[j].MAC })
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal lease history: %w", err)
+	}
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write lease history: %w", err)
+	}
+	if err := os.Rename(tmp, h.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename lease history: %w", err)
+	}
+	if st, err := os.Stat(h.path); err == nil {
+		h.modTime = st.ModTime()
+	}
+	return nil
+}
+
+// releaseLease releases the current lease for ip (optionally checking mac)
+// and records the release in the history. It returns the method used.
+func releaseLease(cfg *Config, d *DNSMasqManager, history *LeaseHistory, ip, mac string) (string, error) {
+	leases, err := d.Leases()
+	if err != nil {
+		return "", fmt.Errorf("read leases: %w", err)
+	}
+	var found *Lease
+	for i := range leases {
+		if leases[i].IP == ip && (mac == "" || normalizeMAC(leases[i].MAC) == normalizeMAC(mac)) {
+			found = &leases[i]
+			break
+		}
+	}
+	if found == nil {
+		return "", fmt.Errorf("lease for %s not found", ip)
+	}
+	view := buildLeaseViews(cfg, []Lease{*found}, nil)[0]
+
+	via, err := d.ReleaseLease(*found, view.Bridge)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("dhcp: released lease %s (%s) via %s", found.IP, found.MAC, via)
+	if err := history.RecordRelease(view, via); err != nil {
+		log.Printf("WARN: lease history: %v", err)
+	}
+	return via, nil
+}
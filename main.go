@@ -13,6 +13,7 @@ import (
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
@@ -25,6 +26,10 @@ var staticFS embed.FS
 
 var version = "dev"
 
+// syncInterval is how often guest DNS names, the lease history and IP
+// conflicts are refreshed.
+const syncInterval = time.Minute
+
 // App holds all application dependencies.
 type App struct {
 	cfg       *Config
@@ -32,7 +37,12 @@ type App struct {
 	sessions  *SessionStore
 	nft       *NFTManager
 	dnsmasq   *DNSMasqManager
+	history   *LeaseHistory
+	ipam      *IPAM
+	conflicts *ConflictDetector
 	proxmox   *ProxmoxClient
+	network   *NetworkChanges
+	targets   *ForwardTargets
 	templates map[string]*template.Template
 }
 
@@ -98,6 +108,11 @@ func main() {
 		"forwards.html",
 		"dhcp.html",
 		"dhcp_form.html",
+		"dns_form.html",
+		"renumber.html",
+		"bridge_form.html",
+		"network.html",
+		"vmnic.html",
 		"login.html",
 	}
 	templates := make(map[string]*template.Template, len(pages))
@@ -117,7 +132,15 @@ func main() {
 	sessions := NewSessionStore(cfg.SessionSecret)
 	nft := NewNFTManager()
 	dnsmasq := NewDNSMasqManager()
-	proxmox := NewProxmoxClient(cfg.ProxmoxURL, cfg.ProxmoxTokenID, cfg.ProxmoxSecret, cfg.ProxmoxNode)
+	proxmox, err := NewProxmoxClient(cfg.ProxmoxURL, cfg.ProxmoxTokenID, cfg.ProxmoxSecret, cfg.ProxmoxNode, cfg.proxmoxTLS())
+	if err != nil {
+		log.Printf("ERROR: proxmox client: %v", err)
+		os.Exit(1)
+	}
+	proxmox.EnableCache(cfg.inventoryTTL())
+	if cfg.ProxmoxInsecure {
+		log.Printf("WARN: proxmox_insecure is set, the Proxmox API certificate is not verified")
+	}
 
 	app := &App{
 		cfg:       cfg,
@@ -125,9 +148,21 @@ func main() {
 		sessions:  sessions,
 		nft:       nft,
 		dnsmasq:   dnsmasq,
+		history:   NewLeaseHistory(leaseHistoryPath),
+		ipam:      NewIPAM(ipamHoldsPath),
+		conflicts: NewConflictDetector(),
 		proxmox:   proxmox,
+		targets:   NewForwardTargets(),
 		templates: templates,
 	}
+	app.network = NewNetworkChanges(networkChangesPath, func(set []BridgeConfig, remove []string) ([]BridgeConfig, error) {
+		return manageBridges(cfg, nft, dnsmasq, set, remove)
+	}, func(add, remove []string) error {
+		cfg.Lock()
+		nodes := cfg.BridgeNodes
+		cfg.Unlock()
+		return syncClusterBridges(proxmox, nodes, add, remove)
+	})
 
 	// Apply saved state on startup
 	if err := nft.Apply(cfg); err != nil {
@@ -135,11 +170,33 @@ func main() {
 	} else {
 		log.Println("nftables rules applied")
 	}
-	if err := dnsmasq.Apply(cfg); err != nil {
+	if _, err := dnsmasq.Apply(cfg); err != nil {
 		log.Printf("WARN: failed to apply dnsmasq config on startup: %v", err)
 	} else {
 		log.Println("dnsmasq config applied")
 	}
+	// Resume network changes applied before a restart and not confirmed yet.
+	if err := app.network.Resume(proxmox); err != nil {
+		log.Printf("ERROR: resume network changes: %v", err)
+	}
+
+	// Keep guest DNS names in sync with Proxmox and record lease history.
+	go func() {
+		for {
+			app.syncDNSHosts()
+			app.recordLeaseHistory()
+			app.scanConflicts()
+			time.Sleep(syncInterval)
+		}
+	}()
+
+	// Forwards to a guest NIC follow the guest's address.
+	go func() {
+		for {
+			app.resolveForwards()
+			time.Sleep(forwardWatchInterval)
+		}
+	}()
 
 	mux := http.NewServeMux()
 	app.SetupRoutes(mux)
@@ -241,24 +298,28 @@ func runInit(configPath string) {
 	pveTokenID := prompt("Proxmox API Token ID (e.g. root@pam!pnat)", "")
 	pveSecret := prompt("Proxmox API Token Secret (UUID)", "")
 	pveNode := prompt("Proxmox Node name", "pve")
+	pveTLS := promptProxmoxTLS(pveURL, prompt)
 	wanIface := prompt("WAN interface", "vmbr0")
 	listenAddr := prompt("Listen address", "127.0.0.1:9090")
 
 	cfg := &Config{
-		ListenAddr:     listenAddr,
-		AuthMode:       authMode,
-		AuthPamService: pamService,
-		AuthAllowUsers: allowUsers,
-		AdminUser:      adminUser,
-		AdminPassHash:  passHash,
-		SessionSecret:  secret,
-		ProxmoxURL:     pveURL,
-		ProxmoxTokenID: pveTokenID,
-		ProxmoxSecret:  pveSecret,
-		ProxmoxNode:    pveNode,
-		WanInterface:   wanIface,
-		Bridges:        []BridgeConfig{},
-		path:           configPath,
+		ListenAddr:         listenAddr,
+		AuthMode:           authMode,
+		AuthPamService:     pamService,
+		AuthAllowUsers:     allowUsers,
+		AdminUser:          adminUser,
+		AdminPassHash:      passHash,
+		SessionSecret:      secret,
+		ProxmoxURL:         pveURL,
+		ProxmoxTokenID:     pveTokenID,
+		ProxmoxSecret:      pveSecret,
+		ProxmoxNode:        pveNode,
+		ProxmoxCAFile:      pveTLS.CAFile,
+		ProxmoxFingerprint: pveTLS.Fingerprint,
+		ProxmoxInsecure:    pveTLS.Insecure,
+		WanInterface:       wanIface,
+		Bridges:            []BridgeConfig{},
+		path:               configPath,
 	}
 
 	// Create config directory if needed
@@ -280,3 +341,36 @@ func runInit(configPath string) {
 	fmt.Printf("\nConfig written to %s\n", configPath)
 	fmt.Println("You can now start pnat with: systemctl start pnat")
 }
+
+// promptProxmoxTLS asks how to verify the Proxmox API certificate. It fetches
+// the certificate and offers to pin its fingerprint (trust on first use);
+// otherwise it asks for a CA bundle, and only an explicit answer disables
+// verification.
+func promptProxmoxTLS(pveURL string, prompt func(label, def string) string) ProxmoxTLS {
+	if !strings.HasPrefix(pveURL, "https://") {
+		return ProxmoxTLS{}
+	}
+	cert, err := fetchCertificate(pveURL)
+	if err != nil {
+		fmt.Printf("Could not fetch the Proxmox certificate: %v\n", err)
+	} else {
+		fp := certFingerprint(cert.Raw)
+		fmt.Println()
+		fmt.Printf("Proxmox API certificate:\n")
+		fmt.Printf("  Subject:     %s\n", cert.Subject)
+		fmt.Printf("  Issuer:      %s\n", cert.Issuer)
+		fmt.Printf("  Valid:       %s - %s\n", cert.NotBefore.Format("2006-01-02"), cert.NotAfter.Format("2006-01-02"))
+		fmt.Printf("  Fingerprint: %s\n", fp)
+		fmt.Println("Compare it with Datacenter > <node> > System > Certificates (pveproxy-ssl.pem or pve-ssl.pem).")
+		if strings.ToLower(prompt("Trust and pin this certificate? (yes/no)", "yes")) == "yes" {
+			return ProxmoxTLS{Fingerprint: fp}
+		}
+	}
+	if ca := prompt("CA bundle to verify the certificate (empty = system roots)", ""); ca != "" {
+		return ProxmoxTLS{CAFile: ca}
+	}
+	if strings.ToLower(prompt("Disable certificate verification (insecure)? (yes/no)", "no")) == "yes" {
+		return ProxmoxTLS{Insecure: true}
+	}
+	return ProxmoxTLS{}
+}
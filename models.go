@@ -1,22 +1,107 @@
 package main
 
+import "time"
+
 // BridgeConfig describes a managed network bridge with NAT, DHCP, and port forwarding.
 type BridgeConfig struct {
 	Name       string        `json:"name"`
 	Subnet     string        `json:"subnet"`
 	GatewayIP  string        `json:"gateway_ip"`
+	SDNZone    string        `json:"sdn_zone,omitempty"` // set for Proxmox SDN VNets
+	VLAN       int           `json:"vlan,omitempty"`     // set for VLANs on a VLAN-aware bridge; Name is <bridge>.<vlan>
 	NATEnabled bool          `json:"nat_enabled"`
 	DHCP       *DHCPConfig   `json:"dhcp,omitempty"`
+	DNS        *DNSConfig    `json:"dns,omitempty"`
+	IPv6       *IPv6Config   `json:"ipv6,omitempty"`
 	Forwards   []PortForward `json:"forwards,omitempty"`
 }
 
-// DHCPConfig describes a basic DHCP pool for a bridge.
+// SubnetPool is the supernet that subnets for new bridges are taken from,
+// e.g. 10.20.0.0/16 split into /24s.
+type SubnetPool struct {
+	CIDR      string `json:"cidr"`
+	PrefixLen int    `json:"prefix_len,omitempty"` // size of each bridge subnet, default 24
+}
+
+// IPv6Config enables router advertisements and optionally DHCPv6 on a bridge.
+// The prefix itself must already be configured on the bridge in Proxmox.
+type IPv6Config struct {
+	Prefix     string `json:"prefix"` // e.g. "fd00:10::/64"
+	Mode       string `json:"mode"`   // "slaac", "stateless" (SLAAC + DHCPv6 info), "stateful"
+	RangeStart string `json:"range_start,omitempty"`
+	RangeEnd   string `json:"range_end,omitempty"`
+	LeaseTime  string `json:"lease_time,omitempty"`
+}
+
+// DHCPConfig describes the DHCP pools of a bridge. RangeStart/RangeEnd is the
+// first dynamic range; Ranges holds any further ones.
 type DHCPConfig struct {
-	RangeStart string `json:"range_start"`
-	RangeEnd   string `json:"range_end"`
-	LeaseTime  string `json:"lease_time"`
-	DNS1       string `json:"dns1"`
-	DNS2       string `json:"dns2"`
+	RangeStart  string          `json:"range_start"`
+	RangeEnd    string          `json:"range_end"`
+	Ranges      []DHCPRange     `json:"ranges,omitempty"`
+	Exclusions  []DHCPExclusion `json:"exclusions,omitempty"` // never handed out dynamically
+	LeaseTime   string          `json:"lease_time"`
+	DNS1        string          `json:"dns1"`
+	DNS2        string          `json:"dns2"`
+	Hosts       []DHCPHost      `json:"hosts,omitempty"`
+	Options     []DHCPOption    `json:"options,omitempty"`
+	AutoReserve bool            `json:"auto_reserve,omitempty"` // reserve an IP for NICs moved onto the bridge
+	Boot        *DHCPBoot       `json:"boot,omitempty"`
+}
+
+// DHCPRange is an additional dynamic address range on a bridge.
+type DHCPRange struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// DHCPExclusion keeps an address or range out of the dynamic pools, e.g. for
+// static VMs, forward targets or appliances.
+type DHCPExclusion struct {
+	Start       string `json:"start"`
+	End         string `json:"end,omitempty"`         // empty for a single address
+	Reservation string `json:"reservation,omitempty"` // MAC of the reservation this was added for
+}
+
+// DHCPBoot holds network boot (PXE) settings for a bridge. Clients reporting a
+// UEFI architecture get UEFIFilename when set, all others get Filename.
+type DHCPBoot struct {
+	Filename     string `json:"filename,omitempty"`      // BIOS / legacy PXE boot file
+	UEFIFilename string `json:"uefi_filename,omitempty"` // x86-64 UEFI boot file
+	NextServer   string `json:"next_server,omitempty"`   // TFTP server IP; empty means this host
+	TFTPRoot     string `json:"tftp_root,omitempty"`     // serve TFTP from this directory via PNAT's dnsmasq
+}
+
+// DHCPOption is an extra option sent to DHCP clients on a bridge. Either Name
+// refers to a known option (see dhcpOptionSpecs) or Code is a raw option number.
+type DHCPOption struct {
+	ID    string `json:"id"`
+	Name  string `json:"name,omitempty"`
+	Code  int    `json:"code,omitempty"`
+	Value string `json:"value"`
+}
+
+// DHCPHost is a static DHCP reservation (MAC to IP) on a bridge.
+type DHCPHost struct {
+	MAC       string `json:"mac"`
+	IP        string `json:"ip"`
+	Hostname  string `json:"hostname,omitempty"`
+	LeaseTime string `json:"lease_time,omitempty"`
+}
+
+// DNSConfig enables the dnsmasq resolver on a bridge's gateway IP.
+type DNSConfig struct {
+	Domain    string      `json:"domain"`
+	Upstreams []string    `json:"upstreams,omitempty"`
+	VMNames   bool        `json:"vm_names"` // publish Proxmox guest names as name.domain
+	Records   []DNSRecord `json:"records,omitempty"`
+}
+
+// DNSRecord is a custom static DNS record served on a bridge.
+type DNSRecord struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"` // "A" or "CNAME"
+	Value string `json:"value"`
 }
 
 // PortForward describes a single DNAT rule.
@@ -24,8 +109,10 @@ type PortForward struct {
 	ID       string `json:"id"`
 	Protocol string `json:"protocol"` // "tcp", "udp", "tcp+udp"
 	ExtPort  uint16 `json:"ext_port"`
-	IntIP    string `json:"int_ip"`
+	IntIP    string `json:"int_ip"` // resolved from VMID and NIC when set
 	IntPort  uint16 `json:"int_port"`
+	VMID     int    `json:"vmid,omitempty"` // target guest instead of a fixed int_ip
+	NIC      string `json:"nic,omitempty"`  // net key of the target guest NIC
 	Comment  string `json:"comment"`
 	Enabled  bool   `json:"enabled"`
 }
@@ -36,12 +123,34 @@ type VM struct {
 	Name   string `json:"name"`
 	Status string `json:"status"`
 	Type   string `json:"type"` // "qemu" or "lxc"
+	Node   string `json:"node,omitempty"`
+	Local  bool   `json:"local"` // runs on this node, so its NICs are on our bridges
 }
 
-// Lease represents a DHCP lease from dnsmasq.
+// Lease represents a DHCP lease from dnsmasq. DHCPv6 leases carry the IAID and
+// client DUID; their MAC is derived from the DUID when it embeds one.
 type Lease struct {
-	Timestamp string `json:"timestamp"`
-	MAC       string `json:"mac"`
-	IP        string `json:"ip"`
-	Hostname  string `json:"hostname"`
+	Timestamp string    `json:"timestamp"` // raw expiry from the lease file, Unix seconds, "0" for infinite
+	Expires   time.Time `json:"expires"`   // zero for infinite leases
+	MAC       string    `json:"mac"`
+	IP        string    `json:"ip"`
+	Hostname  string    `json:"hostname"`
+	ClientID  string    `json:"client_id,omitempty"`
+	IAID      string    `json:"iaid,omitempty"`
+	DUID      string    `json:"duid,omitempty"`
+}
+
+// LeaseRecord is the persistent history of one client (per MAC and address family).
+type LeaseRecord struct {
+	MAC         string    `json:"mac"`
+	IPv6        bool      `json:"ipv6,omitempty"`
+	IP          string    `json:"ip"`
+	Hostname    string    `json:"hostname,omitempty"`
+	Bridge      string    `json:"bridge,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	PreviousIPs []string  `json:"previous_ips,omitempty"` // most recent first
+	ReleasedAt  time.Time `json:"released_at,omitzero"`   // last manual release from PNAT
+	ReleasedIP  string    `json:"released_ip,omitempty"`
+	ReleasedVia string    `json:"released_via,omitempty"` // "dhcp_release" or "lease file"
 }
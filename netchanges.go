@@ -0,0 +1,457 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	interfacesFile = "/etc/network/interfaces"
+	// networkChangesPath keeps the reviewed network changes across restarts.
+	networkChangesPath = "/var/lib/pnat/network-changes.json"
+	// defaultNetworkConfirm is how long applied network changes wait to be
+	// confirmed before they are reverted.
+	defaultNetworkConfirm = 60 * time.Second
+	// maxNetworkConfirm bounds the confirmation timeout users can choose.
+	maxNetworkConfirm = 10 * time.Minute
+)
+
+// NetworkChanges reviews Proxmox network changes before they are applied.
+// Bridges created from PNAT are staged with their PNAT config and only managed
+// once the network is reloaded. Applying arms a safety timer: unless the
+// changes are confirmed in time, the previous /etc/network/interfaces is
+// restored, so a change that cuts the host off the network undoes itself.
+// Deleted bridges lose their PNAT config once applied. Bridges are copied to
+// or removed from the bridge nodes only once the changes are confirmed.
+//
+// The state is kept in a file shared by the web server and the TUI, so a
+// restart between apply and confirm resumes the timer, or reverts at once if
+// the deadline has passed.
+type NetworkChanges struct {
+	path string
+	// manage sets and removes managed bridges, applies the PNAT config and
+	// returns the previous config of the bridges it replaced or removed.
+	manage func(set []BridgeConfig, remove []string) ([]BridgeConfig, error)
+	// cluster creates and deletes the copies of bridges on the bridge nodes.
+	cluster func(add, remove []string) error
+
+	mu    sync.Mutex
+	px    *ProxmoxClient // client the timer reverts with
+	timer *time.Timer
+	own   bool // the armed changes were applied by this process
+}
+
+// networkState is the persisted state of the reviewed network changes.
+type networkState struct {
+	Staged  []BridgeConfig `json:"staged,omitempty"`  // managed once the changes are applied
+	Deleted []string       `json:"deleted,omitempty"` // unmanaged once the changes are applied
+
+	// Set while applied changes await confirmation.
+	Backup   []byte         `json:"backup,omitempty"`   // interfaces file before the changes
+	Previous []BridgeConfig `json:"previous,omitempty"` // configs the changes replaced
+	Added    []string       `json:"added,omitempty"`    // bridges the changes added to the config
+	Copy     []string       `json:"copy,omitempty"`     // bridges to copy to the bridge nodes
+	Drop     []string       `json:"drop,omitempty"`     // bridges to delete on the bridge nodes
+	Deadline time.Time      `json:"deadline,omitzero"`
+
+	Reverted string `json:"reverted,omitempty"` // what the last automatic revert did
+}
+
+// NetworkStatus is the state of the reviewed network changes.
+type NetworkStatus struct {
+	Diff     string    `json:"diff"`               // staged changes in Proxmox
+	Staged   []string  `json:"staged,omitempty"`   // bridges managed once applied
+	Deleted  []string  `json:"deleted,omitempty"`  // bridges deleted once applied
+	Deadline time.Time `json:"deadline,omitempty"` // applied changes revert at; zero when confirmed
+	Reverted string    `json:"reverted,omitempty"` // last automatic revert
+}
+
+// Awaiting reports whether applied changes still need to be confirmed.
+func (s NetworkStatus) Awaiting() bool { return !s.Deadline.IsZero() }
+
+func NewNetworkChanges(path string, manage func(set []BridgeConfig, remove []string) ([]BridgeConfig, error), cluster func(add, remove []string) error) *NetworkChanges {
+	return &NetworkChanges{path: path, manage: manage, cluster: cluster}
+}
+
+// load reads the state file.
+func (n *NetworkChanges) load() (networkState, error) {
+	var s networkState
+	data, err := os.ReadFile(n.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return s, fmt.Errorf("read network changes: %w", err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("parse network changes: %w", err)
+	}
+	return s, nil
+}
+
+// read returns the current state. It takes no lock, as the file is replaced
+// atomically, so pages can show it while a change holds the config lock.
+func (n *NetworkChanges) read() networkState {
+	s, err := n.load()
+	if err != nil {
+		log.Printf("WARN: %v", err)
+	}
+	return s
+}
+
+// update runs fn on the current state and saves it, holding the state file
+// lock so the web server and the TUI do not change it at the same time.
+func (n *NetworkChanges) update(fn func(s *networkState) error) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	unlock, err := lockFile(n.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	s, err := n.load()
+	if err != nil {
+		return err
+	}
+	ferr := fn(&s)
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal network changes: %w", err)
+	}
+	tmp := n.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("write network changes: %w", err)
+	}
+	if err := os.Rename(tmp, n.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("rename network changes: %w", err)
+	}
+	return ferr
+}
+
+// Stage records the PNAT config of a bridge whose creation is pending in
+// Proxmox.
+func (n *NetworkChanges) Stage(br BridgeConfig) error {
+	return n.update(func(s *networkState) error {
+		for i := range s.Staged {
+			if s.Staged[i].Name == br.Name {
+				s.Staged[i] = br
+				return nil
+			}
+		}
+		s.Staged = append(s.Staged, br)
+		return nil
+	})
+}
+
+// StageDelete records a bridge whose deletion is pending in Proxmox.
+func (n *NetworkChanges) StageDelete(bridge string) error {
+	return n.update(func(s *networkState) error {
+		s.Staged = slices.DeleteFunc(s.Staged, func(b BridgeConfig) bool { return b.Name == bridge })
+		if !slices.Contains(s.Deleted, bridge) {
+			s.Deleted = append(s.Deleted, bridge)
+		}
+		return nil
+	})
+}
+
+// IsStaged reports whether bridge waits to be managed.
+func (n *NetworkChanges) IsStaged(bridge string) bool {
+	for _, b := range n.read().Staged {
+		if b.Name == bridge {
+			return true
+		}
+	}
+	return false
+}
+
+// Status returns the review state without asking Proxmox for the diff.
+func (n *NetworkChanges) Status() NetworkStatus {
+	st := n.read()
+	s := NetworkStatus{Deleted: st.Deleted, Deadline: st.Deadline, Reverted: st.Reverted}
+	for _, b := range st.Staged {
+		s.Staged = append(s.Staged, b.Name)
+	}
+	return s
+}
+
+// AppliedHere reports whether changes applied by this process await
+// confirmation.
+func (n *NetworkChanges) AppliedHere() bool {
+	n.mu.Lock()
+	own := n.own
+	n.mu.Unlock()
+	return own && n.Status().Awaiting()
+}
+
+// Pending returns the review state with the diff staged in Proxmox.
+func (n *NetworkChanges) Pending(px *ProxmoxClient) (NetworkStatus, error) {
+	s := n.Status()
+	diff, err := px.PendingNetworkChanges()
+	s.Diff = diff
+	return s, err
+}
+
+// Apply reloads the network, manages the staged bridges and unmanages the
+// deleted ones. With a timeout
+// the changes are reverted unless Confirm is called before it passes; the
+// revert needs this host to be the Proxmox node, as it restores the
+// interfaces file. Without a timeout the changes are confirmed at once.
+func (n *NetworkChanges) Apply(px *ProxmoxClient, timeout time.Duration) error {
+	return n.update(func(s *networkState) error {
+		if !s.Deadline.IsZero() {
+			return errors.New("the previous network changes are not confirmed yet")
+		}
+		var backup []byte
+		if timeout > 0 {
+			var err error
+			if backup, err = os.ReadFile(interfacesFile); err != nil {
+				return fmt.Errorf("read %s for the safety revert: %w", interfacesFile, err)
+			}
+		}
+		if err := px.ReloadNetwork(); err != nil {
+			return fmt.Errorf("proxmox reload: %w", err)
+		}
+		s.Reverted = ""
+
+		var names, added []string
+		var previous []BridgeConfig
+		deleted := s.Deleted
+		if len(s.Staged) > 0 || len(deleted) > 0 {
+			for _, b := range s.Staged {
+				names = append(names, b.Name)
+			}
+			prev, err := n.manage(s.Staged, deleted)
+			if err != nil {
+				log.Printf("ERROR: apply config for %s: %v", strings.Join(append(names, deleted...), ", "), err)
+			}
+			previous = prev
+			for _, name := range names {
+				if !slices.ContainsFunc(prev, func(b BridgeConfig) bool { return b.Name == name }) {
+					added = append(added, name)
+				}
+			}
+			s.Staged, s.Deleted = nil, nil
+		}
+
+		if timeout == 0 {
+			n.syncNodes(names, deleted)
+			return nil
+		}
+		s.Backup, s.Previous, s.Added, s.Copy, s.Drop = backup, previous, added, names, deleted
+		s.Deadline = time.Now().Add(timeout)
+		n.px, n.own = px, true
+		n.arm(s.Deadline)
+		return nil
+	})
+}
+
+// Resume picks up changes applied before a restart: their timer runs on, or
+// they are reverted at once if the deadline has passed.
+func (n *NetworkChanges) Resume(px *ProxmoxClient) error {
+	return n.update(func(s *networkState) error {
+		if s.Deadline.IsZero() {
+			return nil
+		}
+		n.px = px
+		if time.Now().Before(s.Deadline) {
+			n.arm(s.Deadline)
+			return nil
+		}
+		log.Printf("WARN: network changes were not confirmed before %s, reverting", s.Deadline.Format("15:04:05"))
+		s.Reverted = fmt.Sprintf("Network changes were not confirmed before %s and have been reverted.", s.Deadline.Format("15:04:05"))
+		if err := n.rollback(s, px); err != nil {
+			s.Reverted = fmt.Sprintf("Network changes were not confirmed; reverting them failed: %v", err)
+			return err
+		}
+		return nil
+	})
+}
+
+// arm starts the timer that reverts the changes at deadline unless they have
+// been confirmed or reverted by then, here or in another process. n.mu must
+// be held.
+func (n *NetworkChanges) arm(deadline time.Time) {
+	n.timer = time.AfterFunc(time.Until(deadline), func() {
+		err := n.update(func(s *networkState) error {
+			if !s.Deadline.Equal(deadline) {
+				return nil
+			}
+			log.Printf("WARN: network changes were not confirmed by %s, reverting", deadline.Format("15:04:05"))
+			s.Reverted = fmt.Sprintf("Network changes were not confirmed by %s and have been reverted.", deadline.Format("15:04:05"))
+			if err := n.rollback(s, n.px); err != nil {
+				s.Reverted = fmt.Sprintf("Network changes were not confirmed; reverting them failed: %v", err)
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("ERROR: revert network changes: %v", err)
+		}
+	})
+}
+
+// Confirm keeps the applied changes and copies new bridges to the bridge
+// nodes or deletes them there. It returns false if nothing was waiting for confirmation, e.g.
+// because the changes have already been reverted.
+func (n *NetworkChanges) Confirm() bool {
+	confirmed := false
+	err := n.update(func(s *networkState) error {
+		if s.Deadline.IsZero() {
+			return nil
+		}
+		confirmed = true
+		add, remove := s.Copy, s.Drop
+		n.disarm(s)
+		n.syncNodes(add, remove)
+		return nil
+	})
+	if err != nil {
+		log.Printf("ERROR: confirm network changes: %v", err)
+	}
+	return confirmed
+}
+
+// Revert undoes the applied changes awaiting confirmation, or else discards
+// the changes staged in Proxmox and the bridges waiting for them.
+func (n *NetworkChanges) Revert(px *ProxmoxClient) error {
+	return n.update(func(s *networkState) error {
+		if !s.Deadline.IsZero() {
+			return n.rollback(s, px)
+		}
+		if err := px.RevertNetwork(); err != nil {
+			return err
+		}
+		s.Staged, s.Deleted = nil, nil
+		return nil
+	})
+}
+
+// rollback restores the interfaces file saved by Apply and the config of the
+// bridges it changed. Proxmox installs interfaces.new on reload; if the API
+// cannot be reached the file is installed and reloaded locally. n.mu must be
+// held.
+func (n *NetworkChanges) rollback(s *networkState, px *ProxmoxClient) error {
+	defer n.disarm(s)
+	// The restored file replaces whatever was staged since.
+	s.Staged, s.Deleted = nil, nil
+	next := interfacesFile + ".new"
+	if err := os.WriteFile(next, s.Backup, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", next, err)
+	}
+	if err := px.ReloadNetwork(); err != nil {
+		log.Printf("WARN: proxmox reload failed (%v), reloading locally", err)
+		if err := os.Rename(next, interfacesFile); err != nil {
+			return fmt.Errorf("restore %s: %w", interfacesFile, err)
+		}
+		if out, err := exec.Command("ifreload", "-a").CombinedOutput(); err != nil {
+			return fmt.Errorf("ifreload: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	if len(s.Previous) > 0 || len(s.Added) > 0 {
+		if _, err := n.manage(s.Previous, s.Added); err != nil {
+			return fmt.Errorf("restore the config: %w", err)
+		}
+	}
+	return nil
+}
+
+// disarm forgets the applied changes and stops the timer. n.mu must be held.
+func (n *NetworkChanges) disarm(s *networkState) {
+	if n.timer != nil {
+		n.timer.Stop()
+		n.timer = nil
+	}
+	n.own = false
+	s.Backup, s.Previous, s.Added, s.Copy, s.Drop = nil, nil, nil, nil, nil
+	s.Deadline = time.Time{}
+}
+
+// syncNodes creates and deletes bridges on the bridge nodes once their
+// changes are confirmed.
+func (n *NetworkChanges) syncNodes(add, remove []string) {
+	if len(add)+len(remove) == 0 || n.cluster == nil {
+		return
+	}
+	if err := n.cluster(add, remove); err != nil {
+		log.Printf("ERROR: sync bridges %s on cluster nodes: %v", strings.Join(append(add, remove...), ", "), err)
+	}
+}
+
+// manageBridges sets and removes managed bridges and applies the config; it
+// is called when reviewed network changes are applied or reverted. It returns
+// the previous config of the bridges it replaced or removed.
+func manageBridges(cfg *Config, nft *NFTManager, dnsmasq *DNSMasqManager, set []BridgeConfig, remove []string) ([]BridgeConfig, error) {
+	cfg.Lock()
+	defer cfg.Unlock()
+	var prev []BridgeConfig
+	for _, name := range remove {
+		if br := cfg.FindBridge(name); br != nil {
+			prev = append(prev, *br)
+			cfg.DeleteBridge(name)
+		}
+	}
+	for _, br := range set {
+		if cur := cfg.FindBridge(br.Name); cur != nil {
+			prev = append(prev, *cur)
+			*cur = br
+		} else {
+			cfg.Bridges = append(cfg.Bridges, br)
+		}
+	}
+	if err := cfg.Save(); err != nil {
+		return prev, fmt.Errorf("save config: %w", err)
+	}
+	if err := nft.Apply(cfg); err != nil {
+		return prev, fmt.Errorf("apply nftables: %w", err)
+	}
+	if _, err := dnsmasq.Apply(cfg); err != nil {
+		return prev, fmt.Errorf("apply dnsmasq: %w", err)
+	}
+	return prev, nil
+}
+
+// lockFile takes an exclusive lock on path.lock, creating its directory if
+// needed, and returns the function that releases it.
+func lockFile(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
+
+// parseConfirmTimeout reads a confirmation timeout in seconds; empty means
+// defaultNetworkConfirm and 0 applies without a safety timer.
+func parseConfirmTimeout(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return defaultNetworkConfirm, nil
+	}
+	sec, err := strconv.Atoi(s)
+	if err != nil || sec < 0 || time.Duration(sec)*time.Second > maxNetworkConfirm {
+		return 0, fmt.Errorf("invalid confirmation timeout %q (0-%d seconds)", s, int(maxNetworkConfirm.Seconds()))
+	}
+	return time.Duration(sec) * time.Second, nil
+}
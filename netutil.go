@@ -1,9 +1,15 @@
 package main
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"net"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 func cidrFromSubnetAndGateway(subnet, gateway string) (string, error) {
@@ -74,6 +80,153 @@ func parseCIDRv4(s string) (*net.IPNet, error) {
 	return ipnet, nil
 }
 
+// netsOverlap reports whether two networks share any address.
+func netsOverlap(a, b *net.IPNet) bool {
+	return a.Contains(b.IP.Mask(b.Mask)) || b.Contains(a.IP.Mask(a.Mask))
+}
+
+// wanNetworks returns the IPv4 networks on the WAN interface, or nil when the
+// interface does not exist on this host.
+func wanNetworks(iface string) []*net.IPNet {
+	ifi, err := net.InterfaceByName(iface)
+	if err != nil {
+		return nil
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil
+	}
+	var out []*net.IPNet
+	for _, a := range addrs {
+		ipn, ok := a.(*net.IPNet)
+		if !ok || ipn.IP.To4() == nil {
+			continue
+		}
+		ones, bits := ipn.Mask.Size()
+		if bits == 128 {
+			ones -= 96
+		}
+		mask := net.CIDRMask(ones, 32)
+		out = append(out, &net.IPNet{IP: ipn.IP.To4().Mask(mask), Mask: mask})
+	}
+	return out
+}
+
+// translateHostIP moves ip from network from to network to, keeping its host
+// offset. The offset must address a host in to.
+func translateHostIP(ip string, from, to *net.IPNet) (string, error) {
+	parsed, err := parseIPv4(ip)
+	if err != nil {
+		return "", err
+	}
+	if !from.Contains(parsed) {
+		return "", fmt.Errorf("%s is not in %s", ip, from)
+	}
+	offset := ipToUint32(parsed) - ipToUint32(from.IP.Mask(from.Mask))
+	ones, _ := to.Mask.Size()
+	size := uint64(1) << uint(32-ones)
+	if offset == 0 || uint64(offset) >= size-1 {
+		return "", fmt.Errorf("%s has no host at the same offset in %s", ip, to)
+	}
+	return uint32ToIP(ipToUint32(to.IP.Mask(to.Mask)) + offset).String(), nil
+}
+
+// defaultSubnetPool is used for suggestions when the config has no subnet_pool.
+var defaultSubnetPool = SubnetPool{CIDR: "10.10.0.0/16", PrefixLen: 24}
+
+func validateSubnetPool(p *SubnetPool) error {
+	ipnet, err := parseCIDRv4(p.CIDR)
+	if err != nil {
+		return fmt.Errorf("subnet_pool: invalid cidr %q", p.CIDR)
+	}
+	ones, _ := ipnet.Mask.Size()
+	if p.PrefixLen != 0 && (p.PrefixLen < ones || p.PrefixLen > 30) {
+		return fmt.Errorf("subnet_pool: prefix_len must be between %d and 30", ones)
+	}
+	return nil
+}
+
+// SubnetSuggestion is a free subnet with a default gateway and DHCP range.
+type SubnetSuggestion struct {
+	Subnet     string `json:"subnet"`
+	GatewayIP  string `json:"gateway_ip"`
+	RangeStart string `json:"range_start"`
+	RangeEnd   string `json:"range_end"`
+}
+
+// nextFreeSubnet returns the first subnet of pool that overlaps none of taken.
+func nextFreeSubnet(pool SubnetPool, taken []*net.IPNet) (SubnetSuggestion, error) {
+	ipnet, err := parseCIDRv4(pool.CIDR)
+	if err != nil {
+		return SubnetSuggestion{}, fmt.Errorf("invalid subnet pool %q", pool.CIDR)
+	}
+	plen := pool.PrefixLen
+	if plen == 0 {
+		plen = 24
+	}
+	ones, _ := ipnet.Mask.Size()
+	if plen < ones || plen > 30 {
+		return SubnetSuggestion{}, fmt.Errorf("invalid subnet pool prefix length /%d", plen)
+	}
+	base := uint64(ipToUint32(ipnet.IP.Mask(ipnet.Mask)))
+	step := uint64(1) << uint(32-plen)
+	mask := net.CIDRMask(plen, 32)
+	for i := uint64(0); i < uint64(1)<<uint(plen-ones); i++ {
+		cand := &net.IPNet{IP: uint32ToIP(uint32(base + i*step)), Mask: mask}
+		free := true
+		for _, t := range taken {
+			if netsOverlap(cand, t) {
+				free = false
+				break
+			}
+		}
+		if free {
+			return subnetSuggestion(cand), nil
+		}
+	}
+	return SubnetSuggestion{}, fmt.Errorf("no free /%d left in subnet pool %s", plen, pool.CIDR)
+}
+
+// subnetSuggestion uses the first host as gateway and roughly the 40-80%
+// band of the subnet as DHCP range (.100-.200 for a /24).
+func subnetSuggestion(n *net.IPNet) SubnetSuggestion {
+	ones, _ := n.Mask.Size()
+	base := uint64(ipToUint32(n.IP))
+	size := uint64(1) << uint(32-ones)
+	start, end := base+size*100/256, base+size*200/256
+	if start < base+2 {
+		start = base + 2
+	}
+	if end > base+size-2 {
+		end = base + size - 2
+	}
+	return SubnetSuggestion{
+		Subnet:     n.String(),
+		GatewayIP:  uint32ToIP(uint32(base + 1)).String(),
+		RangeStart: uint32ToIP(uint32(start)).String(),
+		RangeEnd:   uint32ToIP(uint32(end)).String(),
+	}
+}
+
+func parseIPv6(s string) (net.IP, error) {
+	ip := net.ParseIP(s)
+	if ip == nil || ip.To4() != nil {
+		return nil, fmt.Errorf("invalid IPv6 address")
+	}
+	return ip, nil
+}
+
+func parseCIDRv6(s string) (*net.IPNet, error) {
+	ip, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	if ip.To4() != nil {
+		return nil, fmt.Errorf("IPv6 CIDR required")
+	}
+	return ipnet, nil
+}
+
 func parseNetmask(netmask string) (net.IPMask, error) {
 	maskIP := net.ParseIP(netmask).To4()
 	if maskIP == nil {
@@ -129,3 +282,627 @@ func validateDHCPRange(subnet, gateway, start, end string) error {
 	}
 	return nil
 }
+
+// ipRange is an inclusive range of IPv4 addresses.
+type ipRange struct{ lo, hi uint32 }
+
+func (r ipRange) contains(n uint32) bool { return n >= r.lo && n <= r.hi }
+
+func (r ipRange) overlaps(o ipRange) bool { return r.lo <= o.hi && o.lo <= r.hi }
+
+func (r ipRange) String() string {
+	if r.lo == r.hi {
+		return uint32ToIP(r.lo).String()
+	}
+	return uint32ToIP(r.lo).String() + "-" + uint32ToIP(r.hi).String()
+}
+
+func (r DHCPRange) bounds() (ipRange, error) {
+	start, err := parseIPv4(r.Start)
+	if err != nil {
+		return ipRange{}, fmt.Errorf("invalid range start IP %q", r.Start)
+	}
+	end, err := parseIPv4(r.End)
+	if err != nil {
+		return ipRange{}, fmt.Errorf("invalid range end IP %q", r.End)
+	}
+	return ipRange{ipToUint32(start), ipToUint32(end)}, nil
+}
+
+func (e DHCPExclusion) bounds() (ipRange, error) {
+	start, err := parseIPv4(e.Start)
+	if err != nil {
+		return ipRange{}, fmt.Errorf("invalid exclusion %q", e.Start)
+	}
+	end := start
+	if e.End != "" {
+		if end, err = parseIPv4(e.End); err != nil {
+			return ipRange{}, fmt.Errorf("invalid exclusion end %q", e.End)
+		}
+	}
+	if !ipLessOrEqual(start, end) {
+		return ipRange{}, fmt.Errorf("exclusion %s-%s: start must be <= end", e.Start, e.End)
+	}
+	return ipRange{ipToUint32(start), ipToUint32(end)}, nil
+}
+
+// AllRanges returns the first dynamic range followed by the additional ones.
+func (d *DHCPConfig) AllRanges() []DHCPRange {
+	return append([]DHCPRange{{Start: d.RangeStart, End: d.RangeEnd}}, d.Ranges...)
+}
+
+// pools returns the dynamic ranges with all exclusions cut out, sorted by address.
+func (d *DHCPConfig) pools() []ipRange {
+	var pools []ipRange
+	for _, r := range d.AllRanges() {
+		if b, err := r.bounds(); err == nil && b.lo <= b.hi {
+			pools = append(pools, b)
+		}
+	}
+	for _, e := range d.Exclusions {
+		x, err := e.bounds()
+		if err != nil {
+			continue
+		}
+		var next []ipRange
+		for _, p := range pools {
+			if !p.overlaps(x) {
+				next = append(next, p)
+				continue
+			}
+			if p.lo < x.lo {
+				next = append(next, ipRange{p.lo, x.lo - 1})
+			}
+			if p.hi > x.hi {
+				next = append(next, ipRange{x.hi + 1, p.hi})
+			}
+		}
+		pools = next
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].lo < pools[j].lo })
+	return pools
+}
+
+// poolContaining returns the pool that contains ip, if any.
+func poolContaining(pools []ipRange, ip string) (ipRange, bool) {
+	ip4, err := parseIPv4(ip)
+	if err != nil {
+		return ipRange{}, false
+	}
+	n := ipToUint32(ip4)
+	for _, p := range pools {
+		if p.contains(n) {
+			return p, true
+		}
+	}
+	return ipRange{}, false
+}
+
+// syncReservationExclusions drops the exclusion added for the reservation of
+// mac and adds it again if that reservation lies inside a dynamic pool.
+func (d *DHCPConfig) syncReservationExclusions(mac string) {
+	mac = normalizeMAC(mac)
+	excl := make([]DHCPExclusion, 0, len(d.Exclusions)+1)
+	for _, e := range d.Exclusions {
+		if e.Reservation != mac {
+			excl = append(excl, e)
+		}
+	}
+	d.Exclusions = excl
+	for _, h := range d.Hosts {
+		if normalizeMAC(h.MAC) != mac {
+			continue
+		}
+		if _, ok := poolContaining(d.pools(), h.IP); ok {
+			d.Exclusions = append(d.Exclusions, DHCPExclusion{Start: h.IP, Reservation: mac})
+		}
+	}
+}
+
+// syncAllReservationExclusions redoes the exclusions of all reservations for
+// the current pools: reservations now inside a pool are excluded, exclusions
+// of reservations no longer in one are dropped.
+func (d *DHCPConfig) syncAllReservationExclusions() {
+	excl := make([]DHCPExclusion, 0, len(d.Exclusions))
+	for _, e := range d.Exclusions {
+		if e.Reservation == "" {
+			excl = append(excl, e)
+		}
+	}
+	d.Exclusions = excl
+	for _, h := range d.Hosts {
+		d.syncReservationExclusions(h.MAC)
+	}
+}
+
+// validateDHCPPools checks all ranges and exclusions of d. Ranges must not
+// overlap each other or the gateway, and no reservation or static guest IP
+// (static maps IP to a description of its owner) may be left in a pool.
+func validateDHCPPools(subnet, gateway string, d *DHCPConfig, static map[string]string) error {
+	ipnet, err := parseCIDRv4(subnet)
+	if err != nil {
+		return fmt.Errorf("bridge subnet invalid")
+	}
+	var ranges []ipRange
+	for i, r := range d.AllRanges() {
+		if err := validateDHCPRange(subnet, gateway, r.Start, r.End); err != nil {
+			if i == 0 {
+				return err
+			}
+			return fmt.Errorf("range %s-%s: %w", r.Start, r.End, err)
+		}
+		b, _ := r.bounds()
+		for _, o := range ranges {
+			if b.overlaps(o) {
+				return fmt.Errorf("DHCP range %s overlaps %s", b, o)
+			}
+		}
+		ranges = append(ranges, b)
+	}
+	for _, e := range d.Exclusions {
+		x, err := e.bounds()
+		if err != nil {
+			return err
+		}
+		if !ipInNet(uint32ToIP(x.lo), ipnet) || !ipInNet(uint32ToIP(x.hi), ipnet) {
+			return fmt.Errorf("exclusion %s must be within bridge subnet", x)
+		}
+	}
+	pools := d.pools()
+	if len(pools) == 0 {
+		return fmt.Errorf("exclusions leave no addresses in the DHCP ranges")
+	}
+	for _, h := range d.Hosts {
+		if p, ok := poolContaining(pools, h.IP); ok {
+			return fmt.Errorf("reservation %s (%s) is inside DHCP range %s; exclude it or move the range", h.IP, h.MAC, p)
+		}
+	}
+	ips := make([]string, 0, len(static))
+	for ip := range static {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		if p, ok := poolContaining(pools, ip); ok {
+			return fmt.Errorf("static IP %s of %s is inside DHCP range %s; exclude it or move the range", ip, static[ip], p)
+		}
+	}
+	return nil
+}
+
+// parseDHCPRanges parses comma-separated "start-end" entries.
+func parseDHCPRanges(s string) ([]DHCPRange, error) {
+	var out []DHCPRange
+	for _, part := range splitCommaKV(s) {
+		start, end, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, fmt.Errorf("invalid range %q (expected start-end)", part)
+		}
+		r := DHCPRange{Start: strings.TrimSpace(start), End: strings.TrimSpace(end)}
+		if _, err := r.bounds(); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, nil
+}
+
+// parseDHCPExclusions parses comma-separated "ip" or "start-end" entries.
+func parseDHCPExclusions(s string) ([]DHCPExclusion, error) {
+	var out []DHCPExclusion
+	for _, part := range splitCommaKV(s) {
+		start, end, _ := strings.Cut(part, "-")
+		e := DHCPExclusion{Start: strings.TrimSpace(start), End: strings.TrimSpace(end)}
+		if _, err := e.bounds(); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func formatDHCPRanges(ranges []DHCPRange) string {
+	parts := make([]string, 0, len(ranges))
+	for _, r := range ranges {
+		parts = append(parts, r.Start+"-"+r.End)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatDHCPExclusions lists the manual exclusions; those added for
+// reservations are managed automatically and left out.
+func formatDHCPExclusions(excl []DHCPExclusion) string {
+	var parts []string
+	for _, e := range excl {
+		if e.Reservation != "" {
+			continue
+		}
+		if e.End == "" {
+			parts = append(parts, e.Start)
+		} else {
+			parts = append(parts, e.Start+"-"+e.End)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// reservationExclusions returns the exclusions that were added for reservations.
+func reservationExclusions(excl []DHCPExclusion) []DHCPExclusion {
+	var out []DHCPExclusion
+	for _, e := range excl {
+		if e.Reservation != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+var (
+	hostnameRe  = regexp.MustCompile(`(?i)^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	leaseTimeRe = regexp.MustCompile(`^(infinite|[0-9]+[smhdw]?)$`)
+)
+
+func validateDHCPHost(subnet, gateway string, h DHCPHost) error {
+	ipnet, err := parseCIDRv4(subnet)
+	if err != nil {
+		return fmt.Errorf("bridge subnet invalid")
+	}
+	if _, err := net.ParseMAC(h.MAC); err != nil {
+		return fmt.Errorf("invalid reservation MAC %q", h.MAC)
+	}
+	ip, err := parseIPv4(h.IP)
+	if err != nil {
+		return fmt.Errorf("invalid reservation IP %q", h.IP)
+	}
+	if !ipInNet(ip, ipnet) {
+		return fmt.Errorf("reservation IP %s must be within bridge subnet", h.IP)
+	}
+	network := ipnet.IP.Mask(ipnet.Mask)
+	broadcast := make(net.IP, len(network))
+	for i := range network {
+		broadcast[i] = network[i] | ^ipnet.Mask[i]
+	}
+	if ip.Equal(network) || ip.Equal(broadcast) {
+		return fmt.Errorf("reservation IP %s is the network or broadcast address", h.IP)
+	}
+	if gwIP, err := parseIPv4(gateway); err == nil && ip.Equal(gwIP) {
+		return fmt.Errorf("reservation IP %s must not be the gateway IP", h.IP)
+	}
+	if h.Hostname != "" && !hostnameRe.MatchString(h.Hostname) {
+		return fmt.Errorf("invalid reservation hostname %q", h.Hostname)
+	}
+	if h.LeaseTime != "" && !leaseTimeRe.MatchString(h.LeaseTime) {
+		return fmt.Errorf("invalid reservation lease time %q", h.LeaseTime)
+	}
+	return nil
+}
+
+// validateDHCPHosts checks every reservation and rejects duplicate MACs, IPs or hostnames.
+func validateDHCPHosts(subnet, gateway string, hosts []DHCPHost) error {
+	macs := map[string]bool{}
+	ips := map[string]bool{}
+	names := map[string]bool{}
+	for _, h := range hosts {
+		if err := validateDHCPHost(subnet, gateway, h); err != nil {
+			return err
+		}
+		mac := normalizeMAC(h.MAC)
+		if macs[mac] {
+			return fmt.Errorf("duplicate reservation for MAC %s", mac)
+		}
+		macs[mac] = true
+		if ips[h.IP] {
+			return fmt.Errorf("duplicate reservation for IP %s", h.IP)
+		}
+		ips[h.IP] = true
+		if h.Hostname != "" {
+			name := strings.ToLower(h.Hostname)
+			if names[name] {
+				return fmt.Errorf("duplicate reservation hostname %s", h.Hostname)
+			}
+			names[name] = true
+		}
+	}
+	return nil
+}
+
+// nextFreeIPv4 returns the lowest host address in subnet that is neither the
+// gateway nor in used. Excluded addresses of d are never returned; addresses
+// in its dynamic pools only when nothing outside them is free.
+func nextFreeIPv4(subnet, gateway string, used map[string]bool, d *DHCPConfig) (string, error) {
+	ipnet, err := parseCIDRv4(subnet)
+	if err != nil {
+		return "", fmt.Errorf("bridge subnet invalid")
+	}
+	ones, bits := ipnet.Mask.Size()
+	if bits != 32 || ones > 30 {
+		return "", fmt.Errorf("subnet too small")
+	}
+	first := ipToUint32(ipnet.IP.Mask(ipnet.Mask)) + 1
+	last := first + (uint32(1) << uint(bits-ones)) - 3
+
+	var pools, excluded []ipRange
+	if d != nil {
+		pools = d.pools()
+		for _, e := range d.Exclusions {
+			if x, err := e.bounds(); err == nil {
+				excluded = append(excluded, x)
+			}
+		}
+	}
+	inAny := func(rs []ipRange, n uint32) bool {
+		for _, r := range rs {
+			if r.contains(n) {
+				return true
+			}
+		}
+		return false
+	}
+	gw := ""
+	if gwIP, err := parseIPv4(gateway); err == nil {
+		gw = gwIP.String()
+	}
+
+	fallback := ""
+	for n := first; n <= last; n++ {
+		ip := uint32ToIP(n).String()
+		if ip == gw || used[ip] || inAny(excluded, n) {
+			continue
+		}
+		if inAny(pools, n) {
+			if fallback == "" {
+				fallback = ip
+			}
+			continue
+		}
+		return ip, nil
+	}
+	if fallback != "" {
+		return fallback, nil
+	}
+	return "", fmt.Errorf("no free address in %s", subnet)
+}
+
+func uint32ToIP(n uint32) net.IP {
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, n)
+	return ip
+}
+
+var dnsNameRe = regexp.MustCompile(`(?i)^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)*[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// qualifyDNSName appends domain to relative names; names containing a dot are kept as is.
+func qualifyDNSName(name, domain string) string {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if strings.Contains(name, ".") || domain == "" {
+		return name
+	}
+	return name + "." + domain
+}
+
+func validateDNSRecord(rec DNSRecord) error {
+	if !dnsNameRe.MatchString(strings.TrimSuffix(rec.Name, ".")) {
+		return fmt.Errorf("invalid record name %q", rec.Name)
+	}
+	switch rec.Type {
+	case "A":
+		if _, err := parseIPv4(rec.Value); err != nil {
+			return fmt.Errorf("record %s: invalid IPv4 address %q", rec.Name, rec.Value)
+		}
+	case "CNAME":
+		if !dnsNameRe.MatchString(strings.TrimSuffix(rec.Value, ".")) {
+			return fmt.Errorf("record %s: invalid CNAME target %q", rec.Name, rec.Value)
+		}
+	default:
+		return fmt.Errorf("record %s: unsupported type %q (expected A or CNAME)", rec.Name, rec.Type)
+	}
+	return nil
+}
+
+func validateDNSConfig(dns *DNSConfig) error {
+	if !dnsNameRe.MatchString(dns.Domain) {
+		return fmt.Errorf("invalid DNS domain %q", dns.Domain)
+	}
+	for _, u := range dns.Upstreams {
+		if net.ParseIP(u) == nil {
+			return fmt.Errorf("invalid upstream DNS server %q", u)
+		}
+	}
+	seen := map[string]bool{}
+	for _, rec := range dns.Records {
+		if err := validateDNSRecord(rec); err != nil {
+			return err
+		}
+		name := qualifyDNSName(rec.Name, dns.Domain)
+		if seen[name] {
+			return fmt.Errorf("duplicate DNS record %s", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+var (
+	hexOptionRe = regexp.MustCompile(`^[0-9a-fA-F]{2}(:[0-9a-fA-F]{2})*$`)
+	rawOptionRe = regexp.MustCompile(`^[^\r\n#]{1,255}$`)
+)
+
+// normalizeOptionValue trims whitespace around list separators.
+func normalizeOptionValue(v string) string {
+	parts := strings.Split(v, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return strings.Join(parts, ",")
+}
+
+// dhcpManagedCodes are options PNAT sets itself or that must not be overridden.
+var dhcpManagedCodes = map[int]string{
+	1:  "subnet mask",
+	3:  "router (gateway)",
+	6:  "DNS servers",
+	50: "requested address",
+	51: "lease time",
+	53: "message type",
+	54: "server identifier",
+}
+
+func validateDHCPOption(o DHCPOption) error {
+	value := normalizeOptionValue(o.Value)
+	if value == "" {
+		return fmt.Errorf("DHCP option value is required")
+	}
+	if o.Name == "" {
+		if o.Code < 1 || o.Code > 254 {
+			return fmt.Errorf("DHCP option code must be 1-254")
+		}
+		if what, ok := dhcpManagedCodes[o.Code]; ok {
+			return fmt.Errorf("DHCP option %d (%s) is managed by PNAT", o.Code, what)
+		}
+		if !rawOptionRe.MatchString(value) {
+			return fmt.Errorf("DHCP option %d: invalid value", o.Code)
+		}
+		return nil
+	}
+
+	spec, ok := dhcpOptionSpecs[o.Name]
+	if !ok {
+		return fmt.Errorf("unknown DHCP option %q", o.Name)
+	}
+	items := strings.Split(value, ",")
+	switch spec.Kind {
+	case "ipv4-list":
+		for _, it := range items {
+			if _, err := parseIPv4(it); err != nil {
+				return fmt.Errorf("%s: invalid IPv4 address %q", o.Name, it)
+			}
+		}
+	case "domain":
+		if len(items) != 1 || !dnsNameRe.MatchString(items[0]) {
+			return fmt.Errorf("%s: invalid domain %q", o.Name, value)
+		}
+	case "domain-list":
+		for _, it := range items {
+			if !dnsNameRe.MatchString(it) {
+				return fmt.Errorf("%s: invalid domain %q", o.Name, it)
+			}
+		}
+	case "routes":
+		// dnsmasq format: dest/prefix,gateway[,dest/prefix,gateway...]
+		if len(items)%2 != 0 {
+			return fmt.Errorf("%s: expected destination/prefix,gateway pairs", o.Name)
+		}
+		for i := 0; i < len(items); i += 2 {
+			if _, err := parseCIDRv4(items[i]); err != nil {
+				return fmt.Errorf("%s: invalid destination %q", o.Name, items[i])
+			}
+			if _, err := parseIPv4(items[i+1]); err != nil {
+				return fmt.Errorf("%s: invalid gateway %q", o.Name, items[i+1])
+			}
+		}
+	case "uint16":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 68 || n > 65535 {
+			return fmt.Errorf("%s: expected a number between 68 and 65535", o.Name)
+		}
+	case "hex":
+		if !hexOptionRe.MatchString(value) {
+			return fmt.Errorf("%s: expected colon-separated hex bytes", o.Name)
+		}
+	}
+	return nil
+}
+
+// validateDHCPOptions checks every option and rejects the same code twice.
+func validateDHCPOptions(opts []DHCPOption) error {
+	codes := map[int]bool{}
+	for _, o := range opts {
+		if err := validateDHCPOption(o); err != nil {
+			return err
+		}
+		code := o.OptionCode()
+		if codes[code] {
+			return fmt.Errorf("DHCP option %d configured twice", code)
+		}
+		codes[code] = true
+	}
+	return nil
+}
+
+var bootFileRe = regexp.MustCompile(`^[A-Za-z0-9._+/-]{1,128}$`)
+
+// validateDHCPBoot checks network boot settings for a bridge. Raw TFTP server
+// name (66) and bootfile (67) options would contradict them and are rejected.
+func validateDHCPBoot(boot *DHCPBoot, opts []DHCPOption) error {
+	if boot.Filename == "" && boot.UEFIFilename == "" {
+		return fmt.Errorf("boot: a BIOS or UEFI boot filename is required")
+	}
+	for _, f := range []string{boot.Filename, boot.UEFIFilename} {
+		if f != "" && !bootFileRe.MatchString(f) {
+			return fmt.Errorf("boot: invalid filename %q", f)
+		}
+	}
+	if boot.NextServer != "" {
+		if _, err := parseIPv4(boot.NextServer); err != nil {
+			return fmt.Errorf("boot: invalid next-server %q", boot.NextServer)
+		}
+	}
+	if boot.TFTPRoot != "" {
+		if !filepath.IsAbs(boot.TFTPRoot) || filepath.Clean(boot.TFTPRoot) != boot.TFTPRoot || strings.ContainsAny(boot.TFTPRoot, ", \t\r\n") {
+			return fmt.Errorf("boot: TFTP root must be a clean absolute path without spaces or commas")
+		}
+		if boot.TFTPRoot == "/" {
+			return fmt.Errorf("boot: refusing to serve / over TFTP")
+		}
+	}
+	for _, o := range opts {
+		if code := o.OptionCode(); code == 66 || code == 67 {
+			return fmt.Errorf("DHCP option %d conflicts with boot settings", code)
+		}
+	}
+	return nil
+}
+
+// validateIPv6Config checks the address mode, prefix and (for stateful
+// DHCPv6) the pool range of a bridge.
+func validateIPv6Config(v6 *IPv6Config) error {
+	if _, ok := ipv6ModeLabels[v6.Mode]; !ok {
+		return fmt.Errorf("ipv6: unknown mode %q", v6.Mode)
+	}
+	prefix, err := parseCIDRv6(v6.Prefix)
+	if err != nil {
+		return fmt.Errorf("ipv6: invalid prefix %q", v6.Prefix)
+	}
+	ones, _ := prefix.Mask.Size()
+	if v6.Mode != "stateful" {
+		if ones != 64 {
+			return fmt.Errorf("ipv6: SLAAC requires a /64 prefix")
+		}
+	} else if ones < 64 || ones > 120 {
+		return fmt.Errorf("ipv6: prefix length must be between /64 and /120")
+	}
+	if v6.LeaseTime != "" && !leaseTimeRe.MatchString(v6.LeaseTime) {
+		return fmt.Errorf("ipv6: invalid lease time %q", v6.LeaseTime)
+	}
+	if v6.Mode != "stateful" {
+		if v6.RangeStart != "" || v6.RangeEnd != "" {
+			return fmt.Errorf("ipv6: a DHCPv6 range is only used in stateful mode")
+		}
+		return nil
+	}
+	start, err := parseIPv6(v6.RangeStart)
+	if err != nil {
+		return fmt.Errorf("ipv6: invalid range start %q", v6.RangeStart)
+	}
+	end, err := parseIPv6(v6.RangeEnd)
+	if err != nil {
+		return fmt.Errorf("ipv6: invalid range end %q", v6.RangeEnd)
+	}
+	if !prefix.Contains(start) || !prefix.Contains(end) {
+		return fmt.Errorf("ipv6: range must be inside %s", prefix)
+	}
+	if bytes.Compare(start, end) > 0 {
+		return fmt.Errorf("ipv6: range start must be <= range end")
+	}
+	return nil
+}
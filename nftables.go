@@ -15,6 +15,9 @@ const (
 	sysctlFile = "/etc/sysctl.d/90-pnat.conf"
 	sysctlProc = "/proc/sys/net/ipv4/ip_forward"
 	nftTable   = "ip pnat"
+	// nftDNSTable drops DNS on bridges without DNS. It is inet, as dnsmasq
+	// also answers on the bridges' IPv6 addresses.
+	nftDNSTable = "inet pnat_dns"
 )
 
 // NFTManager manages nftables rules for NAT and port forwarding.
@@ -41,6 +44,10 @@ func (n *NFTManager) Apply(cfg *Config) error {
 		}
 	}
 
+	if len(dnsBlockedBridges(cfg)) > 0 {
+		hasRules = true
+	}
+
 	// Enable IP forwarding if any NAT is active
 	if hasNAT {
 		if err := enableIPForward(); err != nil {
@@ -77,32 +84,43 @@ func (n *NFTManager) Apply(cfg *Config) error {
 	return nil
 }
 
-// Remove deletes the pnat nftables table entirely.
+// Remove deletes the pnat nftables tables entirely.
 func (n *NFTManager) Remove() error {
-	out, err := exec.Command(nftBinary, "delete", "table", "ip", "pnat").CombinedOutput()
-	if err != nil {
-		s := string(out)
-		// Ignore "No such file or directory" — table doesn't exist
-		if strings.Contains(s, "No such file or directory") || strings.Contains(s, "does not exist") {
-			return nil
+	for _, table := range []string{nftTable, nftDNSTable} {
+		args := append([]string{"delete", "table"}, strings.Fields(table)...)
+		out, err := exec.Command(nftBinary, args...).CombinedOutput()
+		if err != nil {
+			s := string(out)
+			// Ignore "No such file or directory" — table doesn't exist
+			if strings.Contains(s, "No such file or directory") || strings.Contains(s, "does not exist") {
+				continue
+			}
+			return fmt.Errorf("nft delete table: %w: %s", err, strings.TrimSpace(s))
 		}
-		return fmt.Errorf("nft delete table: %w: %s", err, strings.TrimSpace(s))
+		log.Printf("nftables table %s removed", table)
 	}
-	log.Println("nftables table removed")
 	return nil
 }
 
-// Status returns the current nftables rules for the pnat table.
+// Status returns the current nftables rules for the pnat tables.
 func (n *NFTManager) Status() (string, error) {
-	out, err := exec.Command(nftBinary, "list", "table", "ip", "pnat").CombinedOutput()
-	if err != nil {
-		s := string(out)
-		if strings.Contains(s, "No such file or directory") || strings.Contains(s, "does not exist") {
-			return "(no rules loaded)", nil
+	var sb strings.Builder
+	for _, table := range []string{nftTable, nftDNSTable} {
+		args := append([]string{"list", "table"}, strings.Fields(table)...)
+		out, err := exec.Command(nftBinary, args...).CombinedOutput()
+		if err != nil {
+			s := string(out)
+			if strings.Contains(s, "No such file or directory") || strings.Contains(s, "does not exist") {
+				continue
+			}
+			return "", fmt.Errorf("nft list: %w: %s", err, strings.TrimSpace(s))
 		}
-		return "", fmt.Errorf("nft list: %w: %s", err, strings.TrimSpace(s))
+		sb.Write(out)
 	}
-	return string(out), nil
+	if sb.Len() == 0 {
+		return "(no rules loaded)", nil
+	}
+	return sb.String(), nil
 }
 
 func (n *NFTManager) generateRuleset(cfg *Config) string {
@@ -119,7 +137,8 @@ func (n *NFTManager) generateRuleset(cfg *Config) string {
 
 	for _, b := range cfg.Bridges {
 		for _, f := range b.Forwards {
-			if !f.Enabled {
+			// A forward to a guest NIC without an address forwards nowhere.
+			if !f.Enabled || f.IntIP == "" {
 				continue
 			}
 			comment := ""
@@ -158,9 +177,44 @@ func (n *NFTManager) generateRuleset(cfg *Config) string {
 	sb.WriteString("    }\n")
 	sb.WriteString("}\n")
 
+	// Input chain: dnsmasq answers DNS on every interface it serves DHCP or
+	// RA on, over IPv4 and IPv6, so drop DNS queries on bridges that did not
+	// opt in. Adding the table before deleting it removes it if present.
+	sb.WriteString(fmt.Sprintf("\nadd table %s\n", nftDNSTable))
+	blocked := dnsBlockedBridges(cfg)
+	if len(blocked) == 0 {
+		sb.WriteString(fmt.Sprintf("delete table %s\n", nftDNSTable))
+		return sb.String()
+	}
+	sb.WriteString(fmt.Sprintf("flush table %s\n\n", nftDNSTable))
+	sb.WriteString(fmt.Sprintf("table %s {\n", nftDNSTable))
+	sb.WriteString("    chain input {\n")
+	sb.WriteString("        type filter hook input priority filter; policy accept;\n")
+	for _, name := range blocked {
+		sb.WriteString(fmt.Sprintf(
+			"        iifname %q meta l4proto { tcp, udp } th dport 53 drop\n", name,
+		))
+	}
+	sb.WriteString("    }\n")
+	sb.WriteString("}\n")
+
 	return sb.String()
 }
 
+// dnsBlockedBridges lists DHCP/RA bridges without DNS while DNS runs elsewhere.
+func dnsBlockedBridges(cfg *Config) []string {
+	if !hasDNS(cfg) {
+		return nil
+	}
+	var out []string
+	for _, b := range cfg.Bridges {
+		if (b.DHCP != nil || b.IPv6 != nil) && b.DNS == nil {
+			out = append(out, b.Name)
+		}
+	}
+	return out
+}
+
 func enableIPForward() error {
 	// Set immediately
 	if err := os.WriteFile(sysctlProc, []byte("1"), 0644); err != nil {
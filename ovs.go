@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// Open vSwitch networks: an OVS bridge with an address is managed like a
+// Linux bridge. An OVS internal port (OVSIntPort) is the host's interface on
+// an OVS bridge, optionally tagged; as the gateway of a network it plays the
+// part of a VLAN interface, and guests join it with bridge=<ovs bridge> and
+// the port's tag.
+
+const (
+	ovsBridgeType  = "OVSBridge"
+	ovsIntPortType = "OVSIntPort"
+)
+
+// Ports returns the ports of a Linux or OVS bridge.
+func (n ProxmoxNetwork) Ports() string {
+	if n.Type == ovsBridgeType {
+		return n.OVSPorts
+	}
+	return n.BridgePorts
+}
+
+// Tag returns the VLAN tag of an OVS port, 0 if untagged.
+func (n ProxmoxNetwork) Tag() int {
+	tag, _ := strconv.Atoi(n.OVSTag.String())
+	return tag
+}
+
+// isBridgeType reports whether t is a Linux or OVS bridge.
+func isBridgeType(t string) bool {
+	return t == "bridge" || t == ovsBridgeType
+}
+
+// isNetworkType reports whether an interface of type t can be a managed
+// network: a bridge, a VLAN interface or an OVS internal port.
+func isNetworkType(t string) bool {
+	return isBridgeType(t) || t == "vlan" || t == ovsIntPortType
+}
+
+// bridgePortFits reports whether an uplink of type port can be a port of a
+// bridge of type bridge: OVS bonds and ports go on OVS bridges, Linux bonds
+// and VLANs on Linux bridges, physical NICs on either.
+func bridgePortFits(bridge, port string) bool {
+	switch port {
+	case "eth":
+		return true
+	case "bond", "vlan":
+		return bridge != ovsBridgeType
+	case "OVSBond", "OVSPort":
+		return bridge == ovsBridgeType
+	}
+	return false
+}
+
+// checkOVSBridge makes sure bridge is an OVS bridge on this node.
+func checkOVSBridge(px *ProxmoxClient, bridge string) error {
+	networks, err := px.ListNetworks()
+	if err != nil {
+		return fmt.Errorf("list networks: %w", err)
+	}
+	for _, n := range networks {
+		if n.Iface == bridge && n.Type == ovsBridgeType {
+			return nil
+		}
+	}
+	return fmt.Errorf("no OVS bridge %s on this node", bridge)
+}
+
+// guestNetwork returns the bridge and VLAN tag guest NICs use to join
+// network: the OVS bridge and tag of an OVS internal port, the bridge and tag
+// of a VLAN network, or the bridge itself.
+func guestNetwork(networks []ProxmoxNetwork, network string) (string, int) {
+	for _, n := range networks {
+		if n.Iface == network && n.Type == ovsIntPortType && n.OVSBridge != "" {
+			return n.OVSBridge, n.Tag()
+		}
+	}
+	return splitVLANNetwork(network)
+}
+
+// ovsPortNetworks maps how guests join each OVS internal port, the OVS bridge
+// or <ovs bridge>.<tag>, to the port.
+func ovsPortNetworks(networks []ProxmoxNetwork) map[string]string {
+	out := map[string]string{}
+	for _, n := range networks {
+		if n.Type == ovsIntPortType && n.OVSBridge != "" {
+			out[vlanNetworkName(n.OVSBridge, n.Tag())] = n.Iface
+		}
+	}
+	return out
+}
+
+// nicNetwork returns the network of a guest NIC on bridge with tag; ports is
+// from ovsPortNetworks.
+func nicNetwork(ports map[string]string, bridge string, tag int) string {
+	network := vlanNetworkName(bridge, tag)
+	if port, ok := ports[network]; ok {
+		return port
+	}
+	return network
+}
+
+// guestNetwork returns the bridge and VLAN tag guest NICs use to join network
+// on this node.
+func (app *App) guestNetwork(network string) (string, int) {
+	networks, err := app.proxmox.ListNetworks()
+	if err != nil {
+		log.Printf("WARN: failed to list networks: %v", err)
+	}
+	return guestNetwork(networks, network)
+}
+
+// nicNetwork returns the network of a guest NIC on bridge with tag on this
+// node.
+func (app *App) nicNetwork(bridge string, tag int) string {
+	networks, err := app.proxmox.ListNetworks()
+	if err != nil {
+		log.Printf("WARN: failed to list networks: %v", err)
+	}
+	return nicNetwork(ovsPortNetworks(networks), bridge, tag)
+}
@@ -1,44 +1,93 @@
 package main
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
-// ProxmoxClient is a read-only client for the Proxmox VE API.
+const (
+	// taskTimeout bounds how long WaitTask waits for a Proxmox task.
+	taskTimeout = 2 * time.Minute
+	// taskPollInterval is how often WaitTask reads the task status.
+	taskPollInterval = 500 * time.Millisecond
+)
+
+// ProxmoxClient is a client for the Proxmox VE API. node is the node PNAT
+// runs on; guests on other cluster nodes are reached through the node that
+// ListVMs last saw them on.
 type ProxmoxClient struct {
 	baseURL string
 	tokenID string
 	secret  string
 	node    string
 	client  *http.Client
+
+	mu      sync.Mutex
+	vmNodes map[int]string // VMID -> node, from the last ListVMs
+
+	inv inventoryCache
 }
 
-func NewProxmoxClient(baseURL, tokenID, secret, node string) *ProxmoxClient {
+// NewProxmoxClient returns a client that verifies the API certificate as
+// described by t.
+func NewProxmoxClient(baseURL, tokenID, secret, node string, t ProxmoxTLS) (*ProxmoxClient, error) {
+	tlsCfg, err := t.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
 	return &ProxmoxClient{
 		baseURL: baseURL,
 		tokenID: tokenID,
 		secret:  secret,
 		node:    node,
+		vmNodes: map[int]string{},
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+				TLSClientConfig: tlsCfg,
 			},
 		},
-	}
+	}, nil
 }
 
+// doGet reads path, from the inventory cache when it is enabled. If Proxmox
+// does not answer, the last cached response is returned instead.
 func (p *ProxmoxClient) doGet(path string) ([]byte, error) {
-	return p.doRequest("GET", path, nil)
+	if c, ok := p.lookup(path); ok {
+		return c.body, c.err
+	}
+	body, err := p.doRequest("GET", path, nil)
+	var apiErr *proxmoxAPIError
+	if err != nil && !errors.As(err, &apiErr) {
+		if c, ok := p.stale(path); ok {
+			return c.body, c.err
+		}
+		return nil, err
+	}
+	p.store(path, body, err)
+	return body, err
+}
+
+// proxmoxAPIError is a non-200 answer from Proxmox.
+type proxmoxAPIError struct {
+	status int
+	body   string
+}
+
+func (e *proxmoxAPIError) Error() string {
+	return fmt.Sprintf("proxmox API %d: %s", e.status, e.body)
 }
 
 func (p *ProxmoxClient) doRequest(method, path string, values url.Values) ([]byte, error) {
@@ -47,7 +96,16 @@ func (p *ProxmoxClient) doRequest(method, path string, values url.Values) ([]byt
 	if values != nil {
 		reqBody = strings.NewReader(values.Encode())
 	}
-	req, err := http.NewRequest(method, url, reqBody)
+	ctx := context.Background()
+	if method == "GET" {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, readTimeout)
+		defer cancel()
+	} else {
+		// Writes change what later reads return.
+		defer p.Invalidate()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
 	if err != nil {
 		return nil, err
 	}
@@ -58,16 +116,21 @@ func (p *ProxmoxClient) doRequest(method, path string, values url.Values) ([]byt
 
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("proxmox request: %w", err)
+		err = fmt.Errorf("proxmox request: %w", tlsError(err))
+		p.noteResult(false, err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read response: %w", err)
+		err = fmt.Errorf("read response: %w", err)
+		p.noteResult(false, err)
+		return nil, err
 	}
+	p.noteResult(true, nil)
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("proxmox API %d: %s", resp.StatusCode, string(body))
+		return nil, &proxmoxAPIError{status: resp.StatusCode, body: string(body)}
 	}
 	return body, nil
 }
@@ -76,12 +139,69 @@ func (p *ProxmoxClient) doPut(path string, values url.Values) ([]byte, error) {
 	return p.doRequest("PUT", path, values)
 }
 
-// ListVMs returns all QEMU VMs and LXC containers on the node.
+// ListVMs returns all QEMU VMs and LXC containers in the cluster, marking the
+// ones on this node as local. Without access to /cluster/resources (e.g. a
+// token limited to the node) it lists this node only.
 func (p *ProxmoxClient) ListVMs() ([]VM, error) {
 	if p.baseURL == "" || p.tokenID == "" {
 		return nil, nil
 	}
 
+	vms, err := p.listClusterVMs()
+	var tlsErr *tlsVerifyError
+	if errors.As(err, &tlsErr) {
+		// Listing the node alone would fail the same way.
+		return nil, err
+	}
+	if err != nil {
+		log.Printf("WARN: failed to list cluster resources, listing node %s only: %v", p.node, err)
+		vms = p.listNodeVMs()
+	}
+
+	nodes := make(map[int]string, len(vms))
+	for i := range vms {
+		if vms[i].Node == "" {
+			vms[i].Node = p.node
+		}
+		vms[i].Local = vms[i].Node == p.node
+		nodes[vms[i].VMID] = vms[i].Node
+	}
+	p.mu.Lock()
+	p.vmNodes = nodes
+	p.mu.Unlock()
+
+	sort.Slice(vms, func(i, j int) bool { return vms[i].VMID < vms[j].VMID })
+	return vms, nil
+}
+
+func (p *ProxmoxClient) listClusterVMs() ([]VM, error) {
+	data, err := p.doGet("/cluster/resources?type=vm")
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data []struct {
+			VMID   int    `json:"vmid"`
+			Name   string `json:"name"`
+			Status string `json:"status"`
+			Type   string `json:"type"`
+			Node   string `json:"node"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parse cluster resources: %w", err)
+	}
+	var vms []VM
+	for _, v := range resp.Data {
+		if v.Type != "qemu" && v.Type != "lxc" {
+			continue
+		}
+		vms = append(vms, VM{VMID: v.VMID, Name: v.Name, Status: v.Status, Type: v.Type, Node: v.Node})
+	}
+	return vms, nil
+}
+
+func (p *ProxmoxClient) listNodeVMs() []VM {
 	var vms []VM
 
 	// QEMU VMs
@@ -121,32 +241,80 @@ func (p *ProxmoxClient) ListVMs() ([]VM, error) {
 			}
 		}
 	}
+	return vms
+}
 
-	sort.Slice(vms, func(i, j int) bool { return vms[i].VMID < vms[j].VMID })
-	return vms, nil
+// vmNode returns the node a guest was last seen on, defaulting to this node.
+func (p *ProxmoxClient) vmNode(vmid int) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n := p.vmNodes[vmid]; n != "" {
+		return n
+	}
+	return p.node
+}
+
+// ListNodes returns the names of the cluster nodes, this node first.
+func (p *ProxmoxClient) ListNodes() ([]string, error) {
+	if p.baseURL == "" || p.tokenID == "" {
+		return nil, nil
+	}
+	data, err := p.doGet("/nodes")
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data []struct {
+			Node string `json:"node"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parse node list: %w", err)
+	}
+	nodes := []string{p.node}
+	for _, n := range resp.Data {
+		if n.Node != p.node {
+			nodes = append(nodes, n.Node)
+		}
+	}
+	sort.Strings(nodes[1:])
+	return nodes, nil
 }
 
 // ProxmoxNetwork describes a network interface in Proxmox.
 type ProxmoxNetwork struct {
-	Iface       string `json:"iface"`
-	Type        string `json:"type"`
-	CIDR        string `json:"cidr"`
-	Address     string `json:"address"`
-	Netmask     string `json:"netmask"`
-	Method      string `json:"method"`
-	BridgePorts string `json:"bridge_ports"`
-	BridgeFD    string `json:"bridge_fd"`
-	BridgeSTP   string `json:"bridge_stp"`
-	Autostart   int    `json:"autostart"`
+	Iface       string      `json:"iface"`
+	Type        string      `json:"type"`
+	CIDR        string      `json:"cidr"`
+	CIDR6       string      `json:"cidr6"`
+	Address     string      `json:"address"`
+	Netmask     string      `json:"netmask"`
+	Method      string      `json:"method"`
+	BridgePorts string      `json:"bridge_ports"`
+	BridgeFD    string      `json:"bridge_fd"`
+	BridgeSTP   string      `json:"bridge_stp"`
+	VLANAware   int         `json:"bridge_vlan_aware"`
+	OVSBridge   string      `json:"ovs_bridge"` // OVS bridge of an OVS port or bond
+	OVSPorts    string      `json:"ovs_ports"`
+	OVSBonds    string      `json:"ovs_bonds"`
+	OVSTag      json.Number `json:"ovs_tag"`
+	Autostart   int         `json:"autostart"`
+	MTU         json.Number `json:"mtu"`
+	Comments    string      `json:"comments"`
 }
 
 // ListNetworks returns all network interfaces on the node.
 func (p *ProxmoxClient) ListNetworks() ([]ProxmoxNetwork, error) {
+	return p.ListNetworksOn(p.node)
+}
+
+// ListNetworksOn returns all network interfaces on a cluster node.
+func (p *ProxmoxClient) ListNetworksOn(node string) ([]ProxmoxNetwork, error) {
 	if p.baseURL == "" || p.tokenID == "" {
 		return nil, nil
 	}
 
-	data, err := p.doGet(fmt.Sprintf("/nodes/%s/network", p.node))
+	data, err := p.doGet(fmt.Sprintf("/nodes/%s/network", node))
 	if err != nil {
 		return nil, err
 	}
@@ -162,45 +330,288 @@ func (p *ProxmoxClient) ListNetworks() ([]ProxmoxNetwork, error) {
 
 // CreateBridge creates a Linux bridge on the node via the Proxmox API.
 func (p *ProxmoxClient) CreateBridge(iface, cidr, bridgePorts string) error {
+	return p.CreateBridgeOn(p.node, iface, cidr, bridgePorts)
+}
+
+// CreateBridgeOn creates a Linux bridge on a cluster node.
+func (p *ProxmoxClient) CreateBridgeOn(node, iface, cidr, bridgePorts string) error {
+	return p.createBridgeOn(node, "bridge", iface, cidr, bridgePorts)
+}
+
+// CreateOVSBridge creates an Open vSwitch bridge on the node.
+func (p *ProxmoxClient) CreateOVSBridge(iface, cidr, ports string) error {
+	return p.CreateOVSBridgeOn(p.node, iface, cidr, ports)
+}
+
+// CreateOVSBridgeOn creates an Open vSwitch bridge on a cluster node.
+func (p *ProxmoxClient) CreateOVSBridgeOn(node, iface, cidr, ports string) error {
+	return p.createBridgeOn(node, ovsBridgeType, iface, cidr, ports)
+}
+
+func (p *ProxmoxClient) createBridgeOn(node, typ, iface, cidr, ports string) error {
 	if p.baseURL == "" || p.tokenID == "" {
 		return fmt.Errorf("proxmox API not configured")
 	}
 	values := url.Values{}
 	values.Set("iface", iface)
-	values.Set("type", "bridge")
+	values.Set("type", typ)
 	values.Set("autostart", "1")
-	if bridgePorts != "" {
-		values.Set("bridge_ports", bridgePorts)
+	if ports != "" {
+		if typ == ovsBridgeType {
+			values.Set("ovs_ports", ports)
+		} else {
+			values.Set("bridge_ports", ports)
+		}
 	}
 	if cidr != "" {
 		values.Set("cidr", cidr)
 	}
 
+	_, err := p.doRequest("POST", fmt.Sprintf("/nodes/%s/network", node), values)
+	return err
+}
+
+// CreateVLAN creates the VLAN interface iface (<bridge>.<vlan>) on the node,
+// the gateway of a VLAN network. Like CreateBridge it only stages the change.
+func (p *ProxmoxClient) CreateVLAN(iface, cidr string) error {
+	if p.baseURL == "" || p.tokenID == "" {
+		return fmt.Errorf("proxmox API not configured")
+	}
+	values := url.Values{}
+	values.Set("iface", iface)
+	values.Set("type", "vlan")
+	values.Set("autostart", "1")
+	values.Set("cidr", cidr)
+	_, err := p.doRequest("POST", fmt.Sprintf("/nodes/%s/network", p.node), values)
+	return err
+}
+
+// CreateOVSIntPort creates the OVS internal port iface on ovsBridge, tagged
+// with tag unless it is 0, the gateway of the guests on that bridge and tag.
+// Like CreateBridge it only stages the change.
+func (p *ProxmoxClient) CreateOVSIntPort(iface, ovsBridge string, tag int, cidr string) error {
+	if p.baseURL == "" || p.tokenID == "" {
+		return fmt.Errorf("proxmox API not configured")
+	}
+	values := url.Values{}
+	values.Set("iface", iface)
+	values.Set("type", ovsIntPortType)
+	values.Set("ovs_bridge", ovsBridge)
+	if tag != 0 {
+		values.Set("ovs_tag", strconv.Itoa(tag))
+	}
+	values.Set("autostart", "1")
+	values.Set("cidr", cidr)
 	_, err := p.doRequest("POST", fmt.Sprintf("/nodes/%s/network", p.node), values)
 	return err
 }
 
+// ifaceValues starts an update of iface with what Proxmox requires on every
+// update: its type and, for OVS ports, their OVS bridge.
+func (p *ProxmoxClient) ifaceValues(iface string) (url.Values, error) {
+	networks, err := p.ListNetworks()
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range networks {
+		if n.Iface != iface {
+			continue
+		}
+		values := url.Values{}
+		values.Set("type", n.Type)
+		if n.OVSBridge != "" {
+			values.Set("ovs_bridge", n.OVSBridge)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("no interface %s on node %s", iface, p.node)
+}
+
+// UpdateBridgeCIDR changes the IPv4 address of an existing bridge, VLAN
+// interface or OVS internal port. Like CreateBridge it only stages the
+// change; ReloadNetwork applies it.
+func (p *ProxmoxClient) UpdateBridgeCIDR(iface, cidr string) error {
+	if p.baseURL == "" || p.tokenID == "" {
+		return fmt.Errorf("proxmox API not configured")
+	}
+	values, err := p.ifaceValues(iface)
+	if err != nil {
+		return err
+	}
+	values.Set("cidr", cidr)
+	_, err = p.doPut(fmt.Sprintf("/nodes/%s/network/%s", p.node, url.PathEscape(iface)), values)
+	return err
+}
+
+// BridgeSettings are the editable settings of a Linux bridge. Empty values
+// remove the setting.
+type BridgeSettings struct {
+	CIDR      string // IPv4 address with prefix length
+	Ports     string // space-separated
+	MTU       int
+	Comments  string
+	Autostart bool
+}
+
+// UpdateBridge changes the settings of an existing Linux or OVS bridge on the
+// node. Like CreateBridge it only stages the change; ReloadNetwork applies it.
+func (p *ProxmoxClient) UpdateBridge(iface string, s BridgeSettings) error {
+	if p.baseURL == "" || p.tokenID == "" {
+		return fmt.Errorf("proxmox API not configured")
+	}
+	values, err := p.ifaceValues(iface)
+	if err != nil {
+		return err
+	}
+	portsKey := "bridge_ports"
+	if values.Get("type") == ovsBridgeType {
+		portsKey = "ovs_ports"
+	}
+	var del []string
+	set := func(key, val string) {
+		if val == "" {
+			del = append(del, key)
+		} else {
+			values.Set(key, val)
+		}
+	}
+	set("cidr", s.CIDR)
+	set(portsKey, s.Ports)
+	set("comments", s.Comments)
+	if s.MTU > 0 {
+		values.Set("mtu", strconv.Itoa(s.MTU))
+	} else {
+		del = append(del, "mtu")
+	}
+	if s.Autostart {
+		values.Set("autostart", "1")
+	} else {
+		values.Set("autostart", "0")
+	}
+	if len(del) > 0 {
+		values.Set("delete", strings.Join(del, ","))
+	}
+	_, err = p.doPut(fmt.Sprintf("/nodes/%s/network/%s", p.node, url.PathEscape(iface)), values)
+	return err
+}
+
+// DeleteNetworkOn stages the removal of an interface on a cluster node;
+// ReloadNetworkOn applies it.
+func (p *ProxmoxClient) DeleteNetworkOn(node, iface string) error {
+	if p.baseURL == "" || p.tokenID == "" {
+		return fmt.Errorf("proxmox API not configured")
+	}
+	_, err := p.doRequest("DELETE", fmt.Sprintf("/nodes/%s/network/%s", node, url.PathEscape(iface)), nil)
+	return err
+}
+
 // ReloadNetwork applies pending network changes via ifreload.
 func (p *ProxmoxClient) ReloadNetwork() error {
+	return p.ReloadNetworkOn(p.node)
+}
+
+// ReloadNetworkOn applies pending network changes on a cluster node.
+func (p *ProxmoxClient) ReloadNetworkOn(node string) error {
 	if p.baseURL == "" || p.tokenID == "" {
 		return fmt.Errorf("proxmox API not configured")
 	}
-	_, err := p.doRequest("PUT", fmt.Sprintf("/nodes/%s/network", p.node), url.Values{})
+	_, err := p.doRequest("PUT", fmt.Sprintf("/nodes/%s/network", node), url.Values{})
 	return err
 }
 
+// WaitTask waits for the Proxmox task upid to finish and returns its failure,
+// if any. Task status is always read fresh.
+func (p *ProxmoxClient) WaitTask(upid string) error {
+	// UPID:<node>:<pid>:<pstart>:<starttime>:<type>:<id>:<user>:
+	parts := strings.Split(upid, ":")
+	if len(parts) < 3 || parts[0] != "UPID" {
+		return fmt.Errorf("invalid task id %q", upid)
+	}
+	path := fmt.Sprintf("/nodes/%s/tasks/%s/status", url.PathEscape(parts[1]), url.PathEscape(upid))
+	deadline := time.Now().Add(taskTimeout)
+	for {
+		data, err := p.doRequest("GET", path, nil)
+		if err != nil {
+			return fmt.Errorf("task status: %w", err)
+		}
+		var resp struct {
+			Data struct {
+				Status     string `json:"status"`
+				ExitStatus string `json:"exitstatus"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return fmt.Errorf("parse task status: %w", err)
+		}
+		if resp.Data.Status == "stopped" {
+			if resp.Data.ExitStatus != "OK" {
+				return fmt.Errorf("task %s failed: %s", upid, resp.Data.ExitStatus)
+			}
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("task %s still running after %s", upid, taskTimeout)
+		}
+		time.Sleep(taskPollInterval)
+	}
+}
+
+// PendingNetworkChanges returns the diff between /etc/network/interfaces and
+// the staged changes Proxmox applies on the next reload; empty if none. It is
+// always read fresh, as changes may have been staged outside PNAT.
+func (p *ProxmoxClient) PendingNetworkChanges() (string, error) {
+	if p.baseURL == "" || p.tokenID == "" {
+		return "", nil
+	}
+	path := fmt.Sprintf("/nodes/%s/network", p.node)
+	p.forget(path)
+	data, err := p.doGet(path)
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Changes string `json:"changes"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return "", fmt.Errorf("parse network list: %w", err)
+	}
+	return resp.Changes, nil
+}
+
+// RevertNetwork discards the staged network changes on the node.
+func (p *ProxmoxClient) RevertNetwork() error {
+	if p.baseURL == "" || p.tokenID == "" {
+		return fmt.Errorf("proxmox API not configured")
+	}
+	_, err := p.doRequest("DELETE", fmt.Sprintf("/nodes/%s/network", p.node), nil)
+	return err
+}
+
+// vmConfigPath returns the API path of a guest's config on its node.
+func (p *ProxmoxClient) vmConfigPath(vmType string, vmid int) (string, error) {
+	switch vmType {
+	case "qemu", "lxc":
+		return fmt.Sprintf("/nodes/%s/%s/%d/config", p.vmNode(vmid), vmType, vmid), nil
+	}
+	return "", fmt.Errorf("unknown VM type %q", vmType)
+}
+
+// GetVMConfigFresh reads a guest config bypassing the inventory cache, for
+// read-modify-write updates.
+func (p *ProxmoxClient) GetVMConfigFresh(vmType string, vmid int) (map[string]string, error) {
+	if path, err := p.vmConfigPath(vmType, vmid); err == nil {
+		p.forget(path)
+	}
+	return p.GetVMConfig(vmType, vmid)
+}
+
 func (p *ProxmoxClient) GetVMConfig(vmType string, vmid int) (map[string]string, error) {
 	if p.baseURL == "" || p.tokenID == "" {
 		return nil, nil
 	}
-	var path string
-	switch vmType {
-	case "qemu":
-		path = fmt.Sprintf("/nodes/%s/qemu/%d/config", p.node, vmid)
-	case "lxc":
-		path = fmt.Sprintf("/nodes/%s/lxc/%d/config", p.node, vmid)
-	default:
-		return nil, fmt.Errorf("unknown VM type %q", vmType)
+	path, err := p.vmConfigPath(vmType, vmid)
+	if err != nil {
+		return nil, err
 	}
 	data, err := p.doGet(path)
 	if err != nil {
@@ -232,19 +643,68 @@ func (p *ProxmoxClient) GetVMConfig(vmType string, vmid int) (map[string]string,
 	return out, nil
 }
 
+// GuestAgentIPs returns the addresses the QEMU guest agent of a running VM
+// reports, as "ip/prefix" keyed by normalized MAC. Loopback and link-local
+// addresses are left out.
+func (p *ProxmoxClient) GuestAgentIPs(vmid int) (map[string][]string, error) {
+	if p.baseURL == "" || p.tokenID == "" {
+		return nil, nil
+	}
+	data, err := p.doGet(fmt.Sprintf("/nodes/%s/qemu/%d/agent/network-get-interfaces", p.vmNode(vmid), vmid))
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data struct {
+			Result []struct {
+				Name        string `json:"name"`
+				MAC         string `json:"hardware-address"`
+				IPAddresses []struct {
+					IP     string `json:"ip-address"`
+					Type   string `json:"ip-address-type"`
+					Prefix int    `json:"prefix"`
+				} `json:"ip-addresses"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parse guest agent interfaces: %w", err)
+	}
+	out := map[string][]string{}
+	for _, iface := range resp.Data.Result {
+		mac := normalizeMAC(iface.MAC)
+		if mac == "" {
+			continue
+		}
+		for _, a := range iface.IPAddresses {
+			ip := net.ParseIP(a.IP)
+			if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+				continue
+			}
+			out[mac] = append(out[mac], fmt.Sprintf("%s/%d", ip, a.Prefix))
+		}
+	}
+	return out, nil
+}
+
+// agentEnabled reports whether a QEMU config has the guest agent enabled
+// ("agent: 1" or "agent: enabled=1,...").
+func agentEnabled(cfg map[string]string) bool {
+	parts := splitCommaKV(cfg["agent"])
+	if len(parts) == 0 {
+		return false
+	}
+	return parts[0] == "1" || kvGet(parts, "enabled") == "1"
+}
+
 func (p *ProxmoxClient) SetVMConfig(vmType string, vmid int, values url.Values) error {
 	if p.baseURL == "" || p.tokenID == "" {
 		return fmt.Errorf("proxmox API not configured")
 	}
-	var path string
-	switch vmType {
-	case "qemu":
-		path = fmt.Sprintf("/nodes/%s/qemu/%d/config", p.node, vmid)
-	case "lxc":
-		path = fmt.Sprintf("/nodes/%s/lxc/%d/config", p.node, vmid)
-	default:
-		return fmt.Errorf("unknown VM type %q", vmType)
-	}
-	_, err := p.doPut(path, values)
+	path, err := p.vmConfigPath(vmType, vmid)
+	if err != nil {
+		return err
+	}
+	_, err = p.doPut(path, values)
 	return err
 }
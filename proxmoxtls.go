@@ -0,0 +1,168 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ProxmoxTLS says how the Proxmox API certificate is verified. With none of
+// the fields set the system CA roots are used, which suits a certificate from
+// a public CA (e.g. ACME). A pinned fingerprint accepts exactly that leaf
+// certificate, self-signed or not; a CA bundle verifies the chain and host
+// name against its roots. Both may be set. Insecure skips verification and
+// must be chosen explicitly.
+type ProxmoxTLS struct {
+	CAFile      string
+	Fingerprint string
+	Insecure    bool
+}
+
+// errFingerprintMismatch is returned by the pinning check; tlsError turns it
+// into a hint to re-run pnat init.
+type errFingerprintMismatch struct {
+	want, got string
+}
+
+func (e *errFingerprintMismatch) Error() string {
+	return fmt.Sprintf("certificate fingerprint %s does not match pinned %s", e.got, e.want)
+}
+
+// tlsConfig builds the client TLS config for t.
+func (t ProxmoxTLS) tlsConfig() (*tls.Config, error) {
+	if t.Insecure {
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+	cfg := &tls.Config{}
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read proxmox_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("proxmox_ca_file %s: no PEM certificates found", t.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if t.Fingerprint != "" {
+		want, err := normalizeFingerprint(t.Fingerprint)
+		if err != nil {
+			return nil, err
+		}
+		// Without a CA bundle the pin replaces chain verification, so a
+		// self-signed certificate is accepted when it matches.
+		cfg.InsecureSkipVerify = t.CAFile == ""
+		cfg.VerifyPeerCertificate = func(raw [][]byte, _ [][]*x509.Certificate) error {
+			if len(raw) == 0 {
+				return errors.New("no server certificate")
+			}
+			got := certFingerprint(raw[0])
+			if got != want {
+				return &errFingerprintMismatch{want: want, got: got}
+			}
+			return nil
+		}
+	}
+	return cfg, nil
+}
+
+// certFingerprint returns the SHA-256 fingerprint of a DER certificate in the
+// form Proxmox shows it: upper-case hex pairs separated by colons.
+func certFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hexPairs(sum[:])
+}
+
+// normalizeFingerprint accepts a SHA-256 fingerprint with or without colons
+// in either case and returns it in certFingerprint form.
+func normalizeFingerprint(fp string) (string, error) {
+	raw, err := hex.DecodeString(strings.ReplaceAll(strings.TrimSpace(fp), ":", ""))
+	if err != nil || len(raw) != sha256.Size {
+		return "", fmt.Errorf("invalid proxmox_fingerprint %q (expected a SHA-256 fingerprint)", fp)
+	}
+	return hexPairs(raw), nil
+}
+
+func hexPairs(b []byte) string {
+	pairs := make([]string, len(b))
+	for i, c := range b {
+		pairs[i] = fmt.Sprintf("%02X", c)
+	}
+	return strings.Join(pairs, ":")
+}
+
+// tlsVerifyError is a failed certificate verification with a hint on how to
+// fix the configuration.
+type tlsVerifyError struct {
+	err  error
+	hint string
+}
+
+func (e *tlsVerifyError) Error() string {
+	msg := "proxmox TLS verification failed: " + e.err.Error()
+	if e.hint != "" {
+		msg += "; " + e.hint
+	}
+	return msg
+}
+
+func (e *tlsVerifyError) Unwrap() error { return e.err }
+
+// tlsError explains certificate verification failures; other errors are
+// returned unchanged.
+func tlsError(err error) error {
+	var mismatch *errFingerprintMismatch
+	var unknown x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	var verify *tls.CertificateVerificationError
+	switch {
+	case errors.As(err, &mismatch):
+		return &tlsVerifyError{mismatch, "if the certificate was renewed, run pnat init or update proxmox_fingerprint"}
+	case errors.As(err, &unknown):
+		return &tlsVerifyError{errors.New("certificate signed by an unknown authority"), "set proxmox_fingerprint (pnat init fetches it) or proxmox_ca_file"}
+	case errors.As(err, &hostname):
+		return &tlsVerifyError{hostname, "use the name the certificate was issued for in proxmox_url or pin proxmox_fingerprint"}
+	case errors.As(err, &invalid):
+		return &tlsVerifyError{invalid, ""}
+	case errors.As(err, &verify):
+		return &tlsVerifyError{verify.Err, ""}
+	}
+	return err
+}
+
+// fetchCertificate connects to the Proxmox API at baseURL without verifying
+// it and returns the server's leaf certificate, for trust on first use.
+func fetchCertificate(baseURL string) (*x509.Certificate, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse proxmox URL: %w", err)
+	}
+	if u.Scheme != "https" {
+		return nil, fmt.Errorf("proxmox URL %s does not use https", baseURL)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "443")
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	conn, err := tls.DialWithDialer(dialer, "tcp", host, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", host, err)
+	}
+	defer conn.Close()
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s sent no certificate", host)
+	}
+	return certs[0], nil
+}
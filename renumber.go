@@ -0,0 +1,219 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+)
+
+// RenumberChange is one address rewritten by a bridge renumbering.
+type RenumberChange struct {
+	Item string `json:"item"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+}
+
+// RenumberPlan moves a managed bridge to a new subnet. Every address inside
+// the old subnet keeps its host offset, e.g. 10.10.10.20/24 -> 10.20.5.20/24.
+type RenumberPlan struct {
+	Bridge    string           `json:"bridge"`
+	OldSubnet string           `json:"old_subnet"`
+	NewSubnet string           `json:"new_subnet"`
+	OldCIDR   string           `json:"old_cidr"` // gateway/prefix as set on the Proxmox bridge
+	NewCIDR   string           `json:"new_cidr"`
+	Changes   []RenumberChange `json:"changes"`
+	Warnings  []string         `json:"warnings,omitempty"`
+
+	result BridgeConfig
+}
+
+// PlanRenumber works out how bridge name moves to newSubnet without changing
+// anything. static maps guest static IPs on the bridge to a description; they
+// cannot be rewritten by PNAT and are reported as warnings. The caller must
+// hold the config lock.
+func (c *Config) PlanRenumber(name, newSubnet string, static map[string]string) (*RenumberPlan, error) {
+	br := c.FindBridge(name)
+	if br == nil {
+		return nil, fmt.Errorf("bridge %s not found", name)
+	}
+	if br.SDNZone != "" {
+		return nil, fmt.Errorf("%s is a VNet in SDN zone %s; change its subnet in Proxmox SDN and attach it again", name, br.SDNZone)
+	}
+	from, err := parseCIDRv4(br.Subnet)
+	if err != nil {
+		return nil, fmt.Errorf("bridge %s: invalid subnet %q", name, br.Subnet)
+	}
+	from = &net.IPNet{IP: from.IP.Mask(from.Mask), Mask: from.Mask}
+	normalized, err := subnetFromCIDR(newSubnet)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subnet %q", newSubnet)
+	}
+	to, _ := parseCIDRv4(normalized)
+	if to.String() == from.String() {
+		return nil, fmt.Errorf("bridge %s already uses %s", name, normalized)
+	}
+	if err := c.checkSubnet(name, normalized); err != nil {
+		return nil, err
+	}
+
+	p := &RenumberPlan{Bridge: name, OldSubnet: from.String(), NewSubnet: to.String()}
+	var errs []string
+	// move translates ip if it lies in the old subnet and records the change.
+	move := func(item, ip string) string {
+		if ip == "" {
+			return ip
+		}
+		if parsed := net.ParseIP(ip); parsed == nil || !from.Contains(parsed) {
+			return ip
+		}
+		moved, err := translateHostIP(ip, from, to)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", item, err))
+			return ip
+		}
+		p.Changes = append(p.Changes, RenumberChange{Item: item, Old: ip, New: moved})
+		return moved
+	}
+
+	nb := *br
+	nb.Subnet = to.String()
+	nb.GatewayIP = move("gateway", br.GatewayIP)
+	nb.Forwards = make([]PortForward, len(br.Forwards))
+	for i, f := range br.Forwards {
+		item := fmt.Sprintf("forward %s/%d", f.Protocol, f.ExtPort)
+		if f.Comment != "" {
+			item += " (" + f.Comment + ")"
+		}
+		if f.IntIP != "" {
+			f.IntIP = move(item, f.IntIP)
+		}
+		nb.Forwards[i] = f
+	}
+	if br.DHCP != nil {
+		d := *br.DHCP
+		d.RangeStart = move("DHCP range start", d.RangeStart)
+		d.RangeEnd = move("DHCP range end", d.RangeEnd)
+		d.Ranges = make([]DHCPRange, len(br.DHCP.Ranges))
+		for i, r := range br.DHCP.Ranges {
+			r.Start = move(fmt.Sprintf("DHCP range %d start", i+2), r.Start)
+			r.End = move(fmt.Sprintf("DHCP range %d end", i+2), r.End)
+			d.Ranges[i] = r
+		}
+		d.Exclusions = nil
+		for _, e := range br.DHCP.Exclusions {
+			if e.Reservation != "" {
+				continue // rebuilt from the moved reservations below
+			}
+			e.Start = move("exclusion", e.Start)
+			e.End = move("exclusion end", e.End)
+			d.Exclusions = append(d.Exclusions, e)
+		}
+		d.Hosts = make([]DHCPHost, len(br.DHCP.Hosts))
+		for i, h := range br.DHCP.Hosts {
+			h.IP = move("reservation "+h.MAC, h.IP)
+			d.Hosts[i] = h
+		}
+		d.DNS1 = move("DHCP DNS1", d.DNS1)
+		d.DNS2 = move("DHCP DNS2", d.DNS2)
+		if br.DHCP.Boot != nil {
+			boot := *br.DHCP.Boot
+			boot.NextServer = move("PXE next-server", boot.NextServer)
+			d.Boot = &boot
+		}
+		for _, o := range br.DHCP.Options {
+			if containsAddrIn(o.Value, from) {
+				p.Warnings = append(p.Warnings, fmt.Sprintf("DHCP option %s (%s) refers to the old subnet; edit it after renumbering", o.Label(), o.Value))
+			}
+		}
+		nb.DHCP = &d
+	}
+	if br.DNS != nil {
+		dns := *br.DNS
+		dns.Records = make([]DNSRecord, len(br.DNS.Records))
+		for i, r := range br.DNS.Records {
+			if r.Type == "A" {
+				r.Value = move("DNS record "+r.Name, r.Value)
+			}
+			dns.Records[i] = r
+		}
+		nb.DNS = &dns
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("cannot renumber %s to %s: %s", name, to, strings.Join(errs, "; "))
+	}
+
+	newStatic := map[string]string{}
+	ips := make([]string, 0, len(static))
+	for ip := range static {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		moved, err := translateHostIP(ip, from, to)
+		if err != nil {
+			p.Warnings = append(p.Warnings, fmt.Sprintf("%s has static IP %s, which has no place in %s", static[ip], ip, to))
+			continue
+		}
+		newStatic[moved] = static[ip]
+		p.Warnings = append(p.Warnings, fmt.Sprintf("%s has static IP %s; change it to %s in Proxmox", static[ip], ip, moved))
+	}
+
+	if nb.DHCP != nil {
+		if err := validateDHCPHosts(nb.Subnet, nb.GatewayIP, nb.DHCP.Hosts); err != nil {
+			return nil, err
+		}
+		for _, h := range nb.DHCP.Hosts {
+			nb.DHCP.syncReservationExclusions(h.MAC)
+		}
+		if err := validateDHCPPools(nb.Subnet, nb.GatewayIP, nb.DHCP, newStatic); err != nil {
+			return nil, err
+		}
+	}
+
+	fromOnes, _ := from.Mask.Size()
+	toOnes, _ := to.Mask.Size()
+	p.OldCIDR = fmt.Sprintf("%s/%d", br.GatewayIP, fromOnes)
+	p.NewCIDR = fmt.Sprintf("%s/%d", nb.GatewayIP, toOnes)
+	p.result = nb
+	return p, nil
+}
+
+// stageRenumber stages the new bridge address in Proxmox and the renumbered
+// config, which replaces the bridge once the network changes are applied.
+// The copies on the bridge nodes carry no address and stay as they are; a
+// copy given an address outside PNAT is refused, as it would keep the old
+// subnet.
+func stageRenumber(px *ProxmoxClient, network *NetworkChanges, nodes []string, p *RenumberPlan) error {
+	if network.IsStaged(p.Bridge) {
+		return fmt.Errorf("a change of %s is already pending; apply or revert it first", p.Bridge)
+	}
+	if err := checkBridgeCopies(px, nodes, p.Bridge); err != nil {
+		return err
+	}
+	if err := px.UpdateBridgeCIDR(p.Bridge, p.NewCIDR); err != nil {
+		return fmt.Errorf("Proxmox API error: %w", err)
+	}
+	if err := network.Stage(p.result); err != nil {
+		if rerr := px.UpdateBridgeCIDR(p.Bridge, p.OldCIDR); rerr != nil {
+			log.Printf("ERROR: restore %s address %s: %v", p.Bridge, p.OldCIDR, rerr)
+		}
+		return err
+	}
+	log.Printf("staged renumbering bridge %s from %s to %s (%d addresses)", p.Bridge, p.OldSubnet, p.NewSubnet, len(p.Changes))
+	return nil
+}
+
+// containsAddrIn reports whether any IPv4 address in a free-form option value
+// falls inside n.
+func containsAddrIn(value string, n *net.IPNet) bool {
+	for _, tok := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '/'
+	}) {
+		if ip := net.ParseIP(tok); ip != nil && ip.To4() != nil && n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
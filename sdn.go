@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// sdnIDRe matches Proxmox SDN zone and VNet IDs.
+var sdnIDRe = regexp.MustCompile(`^[a-z][a-z0-9]{1,7}$`)
+
+// SDNZone is a Proxmox SDN zone.
+type SDNZone struct {
+	Zone  string `json:"zone"`
+	Type  string `json:"type"` // "simple", "vlan", "vxlan", "evpn", ...
+	Nodes string `json:"nodes,omitempty"`
+}
+
+// SDNVNet is a virtual network in an SDN zone. On each node it shows up as an
+// interface named after the VNet, which PNAT manages like a bridge.
+type SDNVNet struct {
+	VNet  string `json:"vnet"`
+	Zone  string `json:"zone"`
+	Alias string `json:"alias,omitempty"`
+}
+
+// SDNSubnet is an IPv4 or IPv6 subnet of a VNet.
+type SDNSubnet struct {
+	ID      string `json:"subnet"` // e.g. "zone1-10.0.0.0-24"
+	CIDR    string `json:"cidr"`
+	Gateway string `json:"gateway,omitempty"`
+	SNAT    bool   `json:"snat,omitempty"` // Proxmox NATs the subnet itself
+}
+
+// ListSDNZones returns the SDN zones of the cluster.
+func (p *ProxmoxClient) ListSDNZones() ([]SDNZone, error) {
+	if p.baseURL == "" || p.tokenID == "" {
+		return nil, nil
+	}
+	data, err := p.doGet("/cluster/sdn/zones")
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data []SDNZone `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parse sdn zones: %w", err)
+	}
+	return resp.Data, nil
+}
+
+// ListSDNVNets returns the SDN VNets of the cluster.
+func (p *ProxmoxClient) ListSDNVNets() ([]SDNVNet, error) {
+	if p.baseURL == "" || p.tokenID == "" {
+		return nil, nil
+	}
+	data, err := p.doGet("/cluster/sdn/vnets")
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data []SDNVNet `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parse sdn vnets: %w", err)
+	}
+	return resp.Data, nil
+}
+
+// ListSDNSubnets returns the subnets of a VNet.
+func (p *ProxmoxClient) ListSDNSubnets(vnet string) ([]SDNSubnet, error) {
+	if p.baseURL == "" || p.tokenID == "" {
+		return nil, nil
+	}
+	data, err := p.doGet(fmt.Sprintf("/cluster/sdn/vnets/%s/subnets", url.PathEscape(vnet)))
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Data []struct {
+			Subnet  string `json:"subnet"`
+			CIDR    string `json:"cidr"`
+			Gateway string `json:"gateway"`
+			SNAT    any    `json:"snat"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("parse sdn subnets: %w", err)
+	}
+	var out []SDNSubnet
+	for _, s := range resp.Data {
+		cidr := s.CIDR
+		if cidr == "" {
+			cidr = sdnSubnetCIDR(s.Subnet)
+		}
+		snat := false
+		switch v := s.SNAT.(type) {
+		case float64:
+			snat = v != 0
+		case bool:
+			snat = v
+		case string:
+			snat = v == "1"
+		}
+		out = append(out, SDNSubnet{ID: s.Subnet, CIDR: cidr, Gateway: s.Gateway, SNAT: snat})
+	}
+	return out, nil
+}
+
+// sdnSubnetCIDR turns a subnet ID like "zone1-10.0.0.0-24" into "10.0.0.0/24".
+func sdnSubnetCIDR(id string) string {
+	parts := strings.Split(id, "-")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// CreateSDNZone creates a simple SDN zone.
+func (p *ProxmoxClient) CreateSDNZone(zone string) error {
+	if p.baseURL == "" || p.tokenID == "" {
+		return fmt.Errorf("proxmox API not configured")
+	}
+	values := url.Values{}
+	values.Set("zone", zone)
+	values.Set("type", "simple")
+	_, err := p.doRequest("POST", "/cluster/sdn/zones", values)
+	return err
+}
+
+// CreateSDNVNet creates a VNet in zone.
+func (p *ProxmoxClient) CreateSDNVNet(vnet, zone string) error {
+	if p.baseURL == "" || p.tokenID == "" {
+		return fmt.Errorf("proxmox API not configured")
+	}
+	values := url.Values{}
+	values.Set("vnet", vnet)
+	values.Set("zone", zone)
+	_, err := p.doRequest("POST", "/cluster/sdn/vnets", values)
+	return err
+}
+
+// CreateSDNSubnet adds an IPv4 subnet with gateway to a VNet. SNAT and the
+// SDN DHCP plugin stay off: PNAT does NAT and DHCP on the VNet itself.
+func (p *ProxmoxClient) CreateSDNSubnet(vnet, cidr, gateway string) error {
+	if p.baseURL == "" || p.tokenID == "" {
+		return fmt.Errorf("proxmox API not configured")
+	}
+	values := url.Values{}
+	values.Set("subnet", cidr)
+	values.Set("type", "subnet")
+	values.Set("gateway", gateway)
+	_, err := p.doRequest("POST", fmt.Sprintf("/cluster/sdn/vnets/%s/subnets", url.PathEscape(vnet)), values)
+	return err
+}
+
+// ApplySDN applies pending SDN changes on all nodes and waits for the task
+// doing it, so the VNet interfaces exist once it returns.
+func (p *ProxmoxClient) ApplySDN() error {
+	if p.baseURL == "" || p.tokenID == "" {
+		return fmt.Errorf("proxmox API not configured")
+	}
+	data, err := p.doPut("/cluster/sdn", url.Values{})
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Data string `json:"data"`
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return fmt.Errorf("parse sdn apply: %w", err)
+	}
+	return p.WaitTask(resp.Data)
+}
+
+// listVNetViews returns the VNets as bridge views. A VNet's CIDR is the
+// gateway of its first IPv4 subnet, i.e. the address the node gets on it.
+// The subnets of the VNets are read like guest configs: at most
+// inventoryWorkers at a time, and from the inventory cache when it is enabled.
+func listVNetViews(px *ProxmoxClient, managed map[string]bool) []BridgeView {
+	vnets, err := px.ListSDNVNets()
+	if err != nil {
+		// SDN is optional; clusters without it answer with an error.
+		return nil
+	}
+	subnets := make([][]SDNSubnet, len(vnets))
+	sem := make(chan struct{}, inventoryWorkers)
+	var wg sync.WaitGroup
+	for i, v := range vnets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			subnets[i], _ = px.ListSDNSubnets(v.VNet)
+		}()
+	}
+	wg.Wait()
+
+	var out []BridgeView
+	for i, v := range vnets {
+		view := BridgeView{Name: v.VNet, Zone: v.Zone, Managed: managed[v.VNet]}
+		for _, s := range subnets[i] {
+			ipnet, err := parseCIDRv4(s.CIDR)
+			if err != nil || s.Gateway == "" {
+				continue
+			}
+			ones, _ := ipnet.Mask.Size()
+			view.CIDR = fmt.Sprintf("%s/%d", s.Gateway, ones)
+			view.HasCIDR = true
+			view.SNAT = s.SNAT
+			break
+		}
+		out = append(out, view)
+	}
+	return out
+}
+
+// createSDNVNet creates VNet vnet with subnet and gateway in simple zone zone,
+// creating the zone if needed, and applies the SDN configuration.
+func createSDNVNet(px *ProxmoxClient, zone, vnet, subnet, gateway string) error {
+	zones, err := px.ListSDNZones()
+	if err != nil {
+		return fmt.Errorf("list SDN zones: %w", err)
+	}
+	found := false
+	for _, z := range zones {
+		if z.Zone != zone {
+			continue
+		}
+		if z.Type != "simple" {
+			return fmt.Errorf("SDN zone %s is a %s zone; PNAT creates VNets in simple zones only", zone, z.Type)
+		}
+		found = true
+	}
+	if !found {
+		if err := px.CreateSDNZone(zone); err != nil {
+			return fmt.Errorf("create SDN zone %s: %w", zone, err)
+		}
+	}
+	if err := px.CreateSDNVNet(vnet, zone); err != nil {
+		return fmt.Errorf("create VNet %s: %w", vnet, err)
+	}
+	if err := px.CreateSDNSubnet(vnet, subnet, gateway); err != nil {
+		return fmt.Errorf("create subnet %s on %s: %w", subnet, vnet, err)
+	}
+	if err := px.ApplySDN(); err != nil {
+		return fmt.Errorf("apply SDN: %w", err)
+	}
+	return nil
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"os/exec"
+	"os/signal"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
@@ -32,6 +37,15 @@ type TUIMode struct {
 	nft    *NFTManager
 	dnsmas *DNSMasqManager
 	px     *ProxmoxClient
+	pxErr  error // last error listing guests, e.g. a failed certificate check
+
+	network *NetworkChanges
+	targets *ForwardTargets
+
+	dnsmasqAction DNSMasqAction // how the last apply updated dnsmasq
+	history       *LeaseHistory
+	ipam          *IPAM
+	leaseFilter   string // bridge shown in the DHCP leases panel; "" = all
 }
 
 func runTUI(cfgPath string) {
@@ -42,13 +56,33 @@ func runTUI(cfgPath string) {
 		header:  tview.NewTextView(),
 		footer:  tview.NewTextView(),
 		focus:   map[string]tview.Primitive{},
+		history: NewLeaseHistory(leaseHistoryPath),
+		ipam:    NewIPAM(ipamHoldsPath),
+		targets: NewForwardTargets(),
 	}
+	// Reviewed changes are applied to the saved config rather than m.cfg, as
+	// the safety timer reverts them outside the UI goroutine.
+	m.network = NewNetworkChanges(networkChangesPath, func(set []BridgeConfig, remove []string) ([]BridgeConfig, error) {
+		cfg, err := LoadConfig(cfgPath)
+		if err != nil {
+			return nil, err
+		}
+		return manageBridges(cfg, NewNFTManager(), NewDNSMasqManager(), set, remove)
+	}, func(add, remove []string) error {
+		cfg, err := LoadConfig(cfgPath)
+		if err != nil {
+			return err
+		}
+		return syncClusterBridges(m.px, cfg.BridgeNodes, add, remove)
+	})
 	m.header.SetDynamicColors(true)
 	m.footer.SetDynamicColors(true)
 
 	if err := m.refresh(); err != nil {
 		// Still start UI; show error in footer.
 		m.footer.SetText(fmt.Sprintf("[red]refresh failed:[-] %v", err))
+	} else if err := m.network.Resume(m.px); err != nil {
+		m.footer.SetText(fmt.Sprintf("[red]resume network changes:[-] %v", err))
 	}
 
 	layout := tview.NewFlex().SetDirection(tview.FlexRow).
@@ -94,7 +128,24 @@ func runTUI(cfgPath string) {
 		return ev
 	})
 
-	if err := m.app.SetRoot(layout, true).Run(); err != nil {
+	// The safety timer lives in this process: when the terminal goes away,
+	// e.g. because a network change cut the SSH session, unconfirmed changes
+	// are reverted before exiting.
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP, syscall.SIGTERM)
+	go func() {
+		<-sig
+		m.app.Stop()
+	}()
+
+	err := m.app.SetRoot(layout, true).Run()
+	if m.network.AppliedHere() {
+		fmt.Fprintln(os.Stderr, "reverting unconfirmed network changes")
+		if rerr := m.network.Revert(m.px); rerr != nil {
+			fmt.Fprintf(os.Stderr, "revert network changes: %v\n", rerr)
+		}
+	}
+	if err != nil {
 		panic(err)
 	}
 }
@@ -138,7 +189,14 @@ func (m *TUIMode) drawFooter() {
 	if m.cfg != nil {
 		addr = m.cfg.ListenAddr
 	}
-	m.footer.SetText(fmt.Sprintf("[gray]web:[-] %s  [gray]listen:[-] %s", webState, addr))
+	status := fmt.Sprintf("[gray]web:[-] %s  [gray]listen:[-] %s", webState, addr)
+	if m.dnsmasqAction != "" {
+		status += fmt.Sprintf("  [gray]dnsmasq:[-] %s", m.dnsmasqAction)
+	}
+	if m.pxErr != nil {
+		status += fmt.Sprintf("  [red]%v[-]", m.pxErr)
+	}
+	m.footer.SetText(status)
 }
 
 func (m *TUIMode) redrawAll() {
@@ -157,15 +215,31 @@ func (m *TUIMode) refresh() error {
 	m.cfg = cfg
 	m.nft = NewNFTManager()
 	m.dnsmas = NewDNSMasqManager()
-	m.px = NewProxmoxClient(cfg.ProxmoxURL, cfg.ProxmoxTokenID, cfg.ProxmoxSecret, cfg.ProxmoxNode)
+	px, err := NewProxmoxClient(cfg.ProxmoxURL, cfg.ProxmoxTokenID, cfg.ProxmoxSecret, cfg.ProxmoxNode, cfg.proxmoxTLS())
+	if err != nil {
+		return err
+	}
+	m.px = px
 
-	leases, _ := m.dnsmas.Leases()
+	leases, leaseErr := m.dnsmas.Leases()
 	m.leases = leases
-	vms, _ := m.px.ListVMs()
-	m.vms = vms
+	vms, err := m.px.ListVMs()
+	m.vms, m.pxErr = vms, err
 	m.vmViews = buildVMViews(m.px, vms, leases)
 	m.bridgeIPList = buildBridgeIPLists(m.cfg, m.vmViews)
 	m.pxBridges = buildBridgeViews(m.px, m.cfg)
+
+	// Forwards to a guest NIC follow the guest's address.
+	if err == nil && leaseErr == nil {
+		m.cfg.Lock()
+		changed := m.targets.Resolve(m.cfg, m.vmViews)
+		m.cfg.Unlock()
+		if len(changed) > 0 {
+			if err := m.apply(); err != nil {
+				return fmt.Errorf("apply forward targets: %w", err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -178,9 +252,11 @@ func (m *TUIMode) apply() error {
 	if err := m.nft.Apply(m.cfg); err != nil {
 		return err
 	}
-	if err := m.dnsmas.Apply(m.cfg); err != nil {
+	action, err := m.dnsmas.Apply(m.cfg)
+	if err != nil {
 		return err
 	}
+	m.dnsmasqAction = action
 	return nil
 }
 
@@ -216,7 +292,7 @@ func (m *TUIMode) dashboardPage() tview.Primitive {
 			setCell(r, 3, "OFF", tcell.ColorGray)
 		}
 		if b.DHCP != nil {
-			setCell(r, 4, fmt.Sprintf("%s-%s", b.DHCP.RangeStart, b.DHCP.RangeEnd), tcell.ColorGreen)
+			setCell(r, 4, dhcpRangeText(b.DHCP), tcell.ColorGreen)
 		} else {
 			setCell(r, 4, "disabled", tcell.ColorGray)
 		}
@@ -283,13 +359,30 @@ func (m *TUIMode) forwardsPage() tview.Primitive {
 		id     string
 	}
 	var refs []rowRef
+	migrated := map[string]ForwardWarning{}
+	for _, fw := range migratedForwards(m.cfg, m.vmViews) {
+		migrated[fw.ForwardID] = fw
+	}
 	r := 1
 	for _, b := range m.cfg.Bridges {
 		for _, f := range b.Forwards {
 			table.SetCell(r, 0, tview.NewTableCell(b.Name))
 			table.SetCell(r, 1, tview.NewTableCell(f.Protocol))
 			table.SetCell(r, 2, tview.NewTableCell(strconv.Itoa(int(f.ExtPort))))
-			table.SetCell(r, 3, tview.NewTableCell(fmt.Sprintf("%s:%d", f.IntIP, f.IntPort)))
+			intIP := f.IntIP
+			if intIP == "" {
+				intIP = "-"
+			}
+			if f.VMID != 0 {
+				intIP = f.Target(m.vmViews) + " -> " + intIP
+			}
+			if fw, ok := migrated[f.ID]; ok {
+				table.SetCell(r, 3, tview.NewTableCell(fmt.Sprintf("%s:%d (VM %d on %s)", intIP, f.IntPort, fw.VMID, fw.Node)).SetTextColor(tcell.ColorOrange))
+			} else if f.VMID != 0 && f.IntIP == "" {
+				table.SetCell(r, 3, tview.NewTableCell(fmt.Sprintf("%s:%d (unresolved)", intIP, f.IntPort)).SetTextColor(tcell.ColorRed))
+			} else {
+				table.SetCell(r, 3, tview.NewTableCell(fmt.Sprintf("%s:%d", intIP, f.IntPort)))
+			}
 			table.SetCell(r, 4, tview.NewTableCell(f.Comment))
 			if f.Enabled {
 				table.SetCell(r, 5, tview.NewTableCell("ON").SetTextColor(tcell.ColorGreen))
@@ -324,6 +417,7 @@ func (m *TUIMode) forwardsPage() tview.Primitive {
 		var intIP = ""
 		var intPort = "22"
 		var comment = ""
+		var target = ""
 
 		form.AddDropDown("Bridge", bridgeNames, 0, func(option string, _ int) {
 			selBridge = option
@@ -359,6 +453,7 @@ func (m *TUIMode) forwardsPage() tview.Primitive {
 			}
 			return net.ParseIP(textToCheck) != nil
 		}, func(text string) { intIP = text })
+		intIPField := form.GetFormItem(form.GetFormItemCount() - 1).(*tview.InputField)
 
 		// Dropdown of VM IPs for selected bridge.
 		var vmIPOpts []string
@@ -376,6 +471,8 @@ func (m *TUIMode) forwardsPage() tview.Primitive {
 			}
 		})
 
+		form.AddInputField("Target VM (VMID:netN)", target, 12, nil, func(text string) { target = strings.TrimSpace(text) })
+
 		form.AddInputField("Internal Port", intPort, 6, func(textToCheck string, lastChar rune) bool {
 			if textToCheck == "" {
 				return true
@@ -389,20 +486,41 @@ func (m *TUIMode) forwardsPage() tview.Primitive {
 		form.AddButton("Add", func() {
 			ep, _ := strconv.Atoi(extPort)
 			ip, _ := strconv.Atoi(intPort)
+			f := PortForward{
+				ID:       generateID(),
+				Protocol: proto,
+				ExtPort:  uint16(ep),
+				IntIP:    intIP,
+				IntPort:  uint16(ip),
+				Comment:  comment,
+				Enabled:  true,
+			}
+			if target != "" {
+				vmid, nicKey, err := parseForwardTarget(target)
+				if err != nil {
+					m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+					return
+				}
+				f.VMID, f.NIC, f.IntIP = vmid, nicKey, ""
+			}
 			m.cfg.Lock()
 			br := m.cfg.FindBridge(selBridge)
+			if br != nil && f.VMID != 0 {
+				// Without an address yet the forward waits for one.
+				addr, err := resolveForwardTarget(br, f, m.vmViews)
+				if err != nil && !errors.Is(err, errNoTargetAddress) {
+					m.cfg.Unlock()
+					m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+					return
+				}
+				f.IntIP = addr
+			}
 			if br != nil {
-				br.Forwards = append(br.Forwards, PortForward{
-					ID:       generateID(),
-					Protocol: proto,
-					ExtPort:  uint16(ep),
-					IntIP:    intIP,
-					IntPort:  uint16(ip),
-					Comment:  comment,
-					Enabled:  true,
-				})
+				br.Forwards = append(br.Forwards, f)
 			}
 			m.cfg.Unlock()
+			// The forward now marks the address as used; a hold on it is no longer needed.
+			m.ipam.Release(f.IntIP)
 
 			if err := m.apply(); err != nil {
 				m.footer.SetText(fmt.Sprintf("[red]apply failed:[-] %v", err))
@@ -412,6 +530,22 @@ func (m *TUIMode) forwardsPage() tview.Primitive {
 			m.redrawAll()
 			m.pages.HidePage("modal")
 		})
+		form.AddButton("Next free IP", func() {
+			m.cfg.Lock()
+			br := m.cfg.FindBridge(selBridge)
+			var ip string
+			var err error
+			if br != nil {
+				ip, err = m.ipam.NextFree(br, buildUsedIPs(m.cfg, m.leases, m.vmViews), false)
+			}
+			m.cfg.Unlock()
+			if err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+				return
+			}
+			intIPField.SetText(ip)
+			m.footer.SetText(fmt.Sprintf("next free on %s: %s", selBridge, ip))
+		})
 		form.AddButton("Cancel", func() { m.pages.HidePage("modal") })
 		form.SetCancelFunc(func() { m.pages.HidePage("modal") })
 
@@ -471,7 +605,7 @@ func (m *TUIMode) dhcpPage() tview.Primitive {
 	root := tview.NewFlex().SetDirection(tview.FlexRow)
 
 	table := tview.NewTable().SetBorders(false)
-	table.SetTitle("DHCP (e=edit for selected bridge)").SetBorder(true)
+	table.SetTitle("DHCP (e=edit, h=add reservation, o=options, 6=IPv6, Tab=reservations)").SetBorder(true)
 	table.SetFixed(1, 0)
 	table.SetSelectable(true, false)
 	table.Select(1, 0)
@@ -480,13 +614,14 @@ func (m *TUIMode) dhcpPage() tview.Primitive {
 	table.SetCell(0, 1, tview.NewTableCell("Subnet").SetTextColor(tcell.ColorYellow))
 	table.SetCell(0, 2, tview.NewTableCell("Range").SetTextColor(tcell.ColorYellow))
 	table.SetCell(0, 3, tview.NewTableCell("DNS").SetTextColor(tcell.ColorYellow))
+	table.SetCell(0, 4, tview.NewTableCell("IPv6").SetTextColor(tcell.ColorYellow))
 
 	for i, b := range m.cfg.Bridges {
 		r := i + 1
 		table.SetCell(r, 0, tview.NewTableCell(b.Name))
 		table.SetCell(r, 1, tview.NewTableCell(b.Subnet))
 		if b.DHCP != nil {
-			table.SetCell(r, 2, tview.NewTableCell(fmt.Sprintf("%s-%s", b.DHCP.RangeStart, b.DHCP.RangeEnd)).SetTextColor(tcell.ColorGreen))
+			table.SetCell(r, 2, tview.NewTableCell(dhcpRangeText(b.DHCP)).SetTextColor(tcell.ColorGreen))
 			dns := b.DHCP.DNS1
 			if b.DHCP.DNS2 != "" {
 				dns += "," + b.DHCP.DNS2
@@ -496,58 +631,313 @@ func (m *TUIMode) dhcpPage() tview.Primitive {
 			table.SetCell(r, 2, tview.NewTableCell("disabled").SetTextColor(tcell.ColorGray))
 			table.SetCell(r, 3, tview.NewTableCell("-"))
 		}
+		if b.IPv6 != nil {
+			table.SetCell(r, 4, tview.NewTableCell(fmt.Sprintf("%s %s", b.IPv6.Prefix, b.IPv6.Mode)).SetTextColor(tcell.ColorGreen))
+		} else {
+			table.SetCell(r, 4, tview.NewTableCell("off").SetTextColor(tcell.ColorGray))
+		}
 	}
 
+	leaseViews := buildLeaseViews(m.cfg, m.leases, m.vmViews)
+	attachLeaseHistory(m.history, leaseViews)
+	leaseViews = filterLeaseViews(leaseViews, m.leaseFilter)
+	filterLabel := "all"
+	if m.leaseFilter != "" {
+		filterLabel = m.leaseFilter
+	}
 	leases := tview.NewTable().SetBorders(false)
-	leases.SetTitle(fmt.Sprintf("Leases (%d)", len(m.leases))).SetBorder(true)
+	leases.SetTitle(fmt.Sprintf("Leases on %s (%d) (p=pin, r=release, f=filter bridge, Tab=bridges)", filterLabel, len(leaseViews))).SetBorder(true)
 	leases.SetFixed(1, 0)
 	leases.SetSelectable(true, false)
 	leases.Select(1, 0)
-	leases.SetCell(0, 0, tview.NewTableCell("IP").SetTextColor(tcell.ColorYellow))
-	leases.SetCell(0, 1, tview.NewTableCell("MAC").SetTextColor(tcell.ColorYellow))
-	leases.SetCell(0, 2, tview.NewTableCell("Host").SetTextColor(tcell.ColorYellow))
-	for i, l := range m.leases {
+	for i, s := range []string{"IP", "MAC", "Host", "Bridge", "Expires in", "VM", "Pinned", "Previous IPs"} {
+		leases.SetCell(0, i, tview.NewTableCell(s).SetTextColor(tcell.ColorYellow))
+	}
+	for i, l := range leaseViews {
 		r := i + 1
 		leases.SetCell(r, 0, tview.NewTableCell(l.IP))
 		leases.SetCell(r, 1, tview.NewTableCell(l.MAC))
 		leases.SetCell(r, 2, tview.NewTableCell(l.Hostname))
+		leases.SetCell(r, 3, tview.NewTableCell(l.Bridge))
+		leases.SetCell(r, 4, tview.NewTableCell(l.Remaining))
+		if l.VMID != 0 {
+			leases.SetCell(r, 5, tview.NewTableCell(fmt.Sprintf("%d %s (%s)", l.VMID, l.VMName, l.NICKey)))
+		} else {
+			leases.SetCell(r, 5, tview.NewTableCell("-"))
+		}
+		if l.Reserved {
+			leases.SetCell(r, 6, tview.NewTableCell("yes").SetTextColor(tcell.ColorGreen))
+		} else if l.IsIPv6() {
+			leases.SetCell(r, 6, tview.NewTableCell("-").SetTextColor(tcell.ColorGray))
+		} else {
+			leases.SetCell(r, 6, tview.NewTableCell("no").SetTextColor(tcell.ColorGray))
+		}
+		prev := "-"
+		if l.History != nil && len(l.History.PreviousIPs) > 0 {
+			prev = strings.Join(l.History.PreviousIPs, ", ")
+		}
+		leases.SetCell(r, 7, tview.NewTableCell(prev))
 	}
 
+	hosts := tview.NewTable().SetBorders(false)
+	hosts.SetTitle("Reservations (e=edit, x=delete, Tab=leases)").SetBorder(true)
+	hosts.SetFixed(1, 0)
+	hosts.SetSelectable(true, false)
+	hosts.Select(1, 0)
+	for i, s := range []string{"Bridge", "MAC", "IP", "Hostname", "Lease"} {
+		hosts.SetCell(0, i, tview.NewTableCell(s).SetTextColor(tcell.ColorYellow))
+	}
+	type hostRef struct {
+		bridge string
+		host   DHCPHost
+	}
+	var hostRefs []hostRef
+	for _, b := range m.cfg.Bridges {
+		if b.DHCP == nil {
+			continue
+		}
+		for _, h := range b.DHCP.Hosts {
+			r := len(hostRefs) + 1
+			hosts.SetCell(r, 0, tview.NewTableCell(b.Name))
+			hosts.SetCell(r, 1, tview.NewTableCell(h.MAC))
+			hosts.SetCell(r, 2, tview.NewTableCell(h.IP))
+			hosts.SetCell(r, 3, tview.NewTableCell(h.Hostname))
+			hosts.SetCell(r, 4, tview.NewTableCell(h.LeaseTime))
+			hostRefs = append(hostRefs, hostRef{bridge: b.Name, host: h})
+		}
+	}
+
+	hostForm := func(bridgeName string, h DHCPHost) {
+		origMAC := h.MAC // the reservation being edited, if any
+		form := tview.NewForm()
+		form.SetBorder(true).SetTitle("Reservation on " + bridgeName).SetTitleAlign(tview.AlignLeft)
+
+		if h.IP == "" {
+			// Suggest the next free address outside the DHCP ranges.
+			m.cfg.Lock()
+			if br := m.cfg.FindBridge(bridgeName); br != nil {
+				if ip, err := m.ipam.NextFree(br, buildUsedIPs(m.cfg, m.leases, m.vmViews), false); err == nil {
+					h.IP = ip
+				}
+			}
+			m.cfg.Unlock()
+		}
+		form.AddInputField("MAC", h.MAC, 17, nil, func(text string) { h.MAC = text })
+		form.AddInputField("IP", h.IP, 15, nil, func(text string) { h.IP = text })
+		form.AddInputField("Hostname", h.Hostname, 30, nil, func(text string) { h.Hostname = text })
+		form.AddInputField("Lease time", h.LeaseTime, 10, nil, func(text string) { h.LeaseTime = text })
+
+		form.AddButton("Save", func() {
+			h.MAC = normalizeMAC(h.MAC)
+			h.IP = strings.TrimSpace(h.IP)
+			h.Hostname = strings.TrimSpace(h.Hostname)
+			h.LeaseTime = strings.TrimSpace(h.LeaseTime)
+			m.cfg.Lock()
+			err := fmt.Errorf("bridge not found")
+			if br := m.cfg.FindBridge(bridgeName); br != nil {
+				err = br.ReplaceDHCPHost(origMAC, h)
+			}
+			m.cfg.Unlock()
+			if err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]invalid reservation:[-] %v", err))
+				return
+			}
+			m.ipam.Release(h.IP)
+			if err := m.apply(); err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]apply failed:[-] %v", err))
+				return
+			}
+			_ = m.refresh()
+			m.redrawAll()
+			m.pages.HidePage("modal")
+		})
+		form.AddButton("Cancel", func() { m.pages.HidePage("modal") })
+		form.SetCancelFunc(func() { m.pages.HidePage("modal") })
+
+		m.pages.AddAndSwitchToPage("modal", modal(form, 70, 13), true)
+		m.app.SetFocus(form)
+	}
+
+	hosts.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		if ev.Key() == tcell.KeyTab {
+			m.app.SetFocus(leases)
+			return nil
+		}
+		row, _ := hosts.GetSelection()
+		if row <= 0 || row-1 >= len(hostRefs) {
+			return ev
+		}
+		ref := hostRefs[row-1]
+		switch ev.Rune() {
+		case 'e':
+			hostForm(ref.bridge, ref.host)
+			return nil
+		case 'x':
+			m.cfg.Lock()
+			if br := m.cfg.FindBridge(ref.bridge); br != nil {
+				br.DeleteDHCPHost(ref.host.MAC)
+			}
+			m.cfg.Unlock()
+			if err := m.apply(); err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]apply failed:[-] %v", err))
+			} else {
+				_ = m.refresh()
+				m.redrawAll()
+			}
+			return nil
+		}
+		return ev
+	})
+
+	leases.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		if ev.Key() == tcell.KeyTab {
+			m.app.SetFocus(table)
+			return nil
+		}
+		if ev.Rune() == 'f' {
+			// Cycle: all -> each bridge -> all.
+			next := ""
+			for i, b := range m.cfg.Bridges {
+				if m.leaseFilter == "" {
+					next = b.Name
+					break
+				}
+				if b.Name == m.leaseFilter && i+1 < len(m.cfg.Bridges) {
+					next = m.cfg.Bridges[i+1].Name
+					break
+				}
+			}
+			m.leaseFilter = next
+			m.redrawAll()
+			return nil
+		}
+		if ev.Rune() != 'p' && ev.Rune() != 'r' {
+			return ev
+		}
+		row, _ := leases.GetSelection()
+		if row <= 0 || row-1 >= len(leaseViews) {
+			return nil
+		}
+		l := leaseViews[row-1]
+		if ev.Rune() == 'r' {
+			via, err := releaseLease(m.cfg, m.dnsmas, m.history, l.IP, l.MAC)
+			if err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]release failed:[-] %v", err))
+				return nil
+			}
+			_ = m.refresh()
+			m.redrawAll()
+			m.footer.SetText(fmt.Sprintf("released %s via %s", l.IP, via))
+			return nil
+		}
+		m.cfg.Lock()
+		_, err := m.cfg.ReserveAddress(l.MAC, l.IP, l.Hostname)
+		m.cfg.Unlock()
+		if err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]pin failed:[-] %v", err))
+			return nil
+		}
+		if err := m.apply(); err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]apply failed:[-] %v", err))
+		} else {
+			_ = m.refresh()
+			m.redrawAll()
+		}
+		return nil
+	})
+
 	editForm := func(b *BridgeConfig) {
 		form := tview.NewForm()
 		form.SetBorder(true).SetTitle("DHCP settings").SetTitleAlign(tview.AlignLeft)
 
 		enabled := b.DHCP != nil
+		autoReserve := false
+		var boot DHCPBoot
 		rangeStart, rangeEnd, leaseTime, dns1, dns2 := "", "", "12h", "1.1.1.1", "8.8.8.8"
+		extraRanges, exclusions := "", ""
 		if b.DHCP != nil {
 			rangeStart, rangeEnd = b.DHCP.RangeStart, b.DHCP.RangeEnd
+			extraRanges = formatDHCPRanges(b.DHCP.Ranges)
+			exclusions = formatDHCPExclusions(b.DHCP.Exclusions)
 			if b.DHCP.LeaseTime != "" {
 				leaseTime = b.DHCP.LeaseTime
 			}
 			dns1, dns2 = b.DHCP.DNS1, b.DHCP.DNS2
+			autoReserve = b.DHCP.AutoReserve
+			if b.DHCP.Boot != nil {
+				boot = *b.DHCP.Boot
+			}
 		}
 
 		form.AddCheckbox("Enable DHCP", enabled, func(checked bool) { enabled = checked })
 		form.AddInputField("Range start", rangeStart, 15, nil, func(text string) { rangeStart = text })
 		form.AddInputField("Range end", rangeEnd, 15, nil, func(text string) { rangeEnd = text })
+		form.AddInputField("More ranges", extraRanges, 40, nil, func(text string) { extraRanges = text })
+		form.AddInputField("Exclusions", exclusions, 40, nil, func(text string) { exclusions = text })
 		form.AddInputField("Lease time", leaseTime, 10, nil, func(text string) { leaseTime = text })
 		form.AddInputField("DNS1", dns1, 15, nil, func(text string) { dns1 = text })
 		form.AddInputField("DNS2", dns2, 15, nil, func(text string) { dns2 = text })
+		form.AddCheckbox("Auto-reserve on NIC move", autoReserve, func(checked bool) { autoReserve = checked })
+		form.AddInputField("PXE BIOS file", boot.Filename, 30, nil, func(text string) { boot.Filename = strings.TrimSpace(text) })
+		form.AddInputField("PXE UEFI file", boot.UEFIFilename, 30, nil, func(text string) { boot.UEFIFilename = strings.TrimSpace(text) })
+		form.AddInputField("Next server", boot.NextServer, 15, nil, func(text string) { boot.NextServer = strings.TrimSpace(text) })
+		form.AddInputField("TFTP root", boot.TFTPRoot, 30, nil, func(text string) { boot.TFTPRoot = strings.TrimSpace(text) })
 
 		form.AddButton("Save", func() {
+			var bootCfg *DHCPBoot
+			if boot != (DHCPBoot{}) {
+				bb := boot
+				bootCfg = &bb
+			}
+			ranges, err := parseDHCPRanges(extraRanges)
+			if err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+				return
+			}
+			excl, err := parseDHCPExclusions(exclusions)
+			if err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+				return
+			}
 			m.cfg.Lock()
 			br := m.cfg.FindBridge(b.Name)
 			if br != nil {
 				if !enabled {
 					br.DHCP = nil
 				} else {
-					br.DHCP = &DHCPConfig{
-						RangeStart: rangeStart,
-						RangeEnd:   rangeEnd,
-						LeaseTime:  leaseTime,
-						DNS1:       dns1,
-						DNS2:       dns2,
+					var hosts []DHCPHost
+					var options []DHCPOption
+					if br.DHCP != nil {
+						hosts = br.DHCP.Hosts
+						options = br.DHCP.Options
+					}
+					if bootCfg != nil {
+						if err := validateDHCPBoot(bootCfg, options); err != nil {
+							m.cfg.Unlock()
+							m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+							return
+						}
+					}
+					dhcp := &DHCPConfig{
+						RangeStart:  rangeStart,
+						RangeEnd:    rangeEnd,
+						Ranges:      ranges,
+						Exclusions:  excl,
+						LeaseTime:   leaseTime,
+						DNS1:        dns1,
+						DNS2:        dns2,
+						Hosts:       hosts,
+						Options:     options,
+						AutoReserve: autoReserve,
+						Boot:        bootCfg,
 					}
+					dhcp.syncAllReservationExclusions()
+					if err := validateDHCPPools(br.Subnet, br.GatewayIP, dhcp, staticGuestIPs(m.vmViews, br.Name)); err != nil {
+						m.cfg.Unlock()
+						m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+						return
+					}
+					br.DHCP = dhcp
 				}
 			}
 			m.cfg.Unlock()
@@ -562,12 +952,160 @@ func (m *TUIMode) dhcpPage() tview.Primitive {
 		form.AddButton("Cancel", func() { m.pages.HidePage("modal") })
 		form.SetCancelFunc(func() { m.pages.HidePage("modal") })
 
-		m.pages.AddAndSwitchToPage("modal", modal(form, 80, 20), true)
+		m.pages.AddAndSwitchToPage("modal", modal(form, 80, 34), true)
+		m.app.SetFocus(form)
+	}
+
+	optionsForm := func(bridgeName string) {
+		br := m.cfg.FindBridge(bridgeName)
+		if br == nil || br.DHCP == nil {
+			m.footer.SetText("[red]enable DHCP on the bridge first[-]")
+			return
+		}
+
+		list := tview.NewTable().SetBorders(false)
+		list.SetTitle("Options (x=delete, Tab=form)").SetBorder(true)
+		list.SetFixed(1, 0)
+		list.SetSelectable(true, false)
+		list.Select(1, 0)
+		for i, s := range []string{"Option", "Code", "Value"} {
+			list.SetCell(0, i, tview.NewTableCell(s).SetTextColor(tcell.ColorYellow))
+		}
+		opts := append([]DHCPOption(nil), br.DHCP.Options...)
+		for i, o := range opts {
+			list.SetCell(i+1, 0, tview.NewTableCell(o.Label()))
+			list.SetCell(i+1, 1, tview.NewTableCell(strconv.Itoa(o.OptionCode())))
+			list.SetCell(i+1, 2, tview.NewTableCell(o.Value))
+		}
+
+		names := append(dhcpOptionNames(), "raw")
+		opt := DHCPOption{Name: names[0]}
+		code := ""
+
+		form := tview.NewForm()
+		form.SetBorder(true).SetTitle("Add option on " + bridgeName).SetTitleAlign(tview.AlignLeft)
+		form.AddDropDown("Option", names, 0, func(option string, _ int) { opt.Name = option })
+		form.AddInputField("Code (raw)", code, 4, nil, func(text string) { code = text })
+		form.AddInputField("Value", "", 40, nil, func(text string) { opt.Value = text })
+
+		save := func(next []DHCPOption) {
+			m.cfg.Lock()
+			err := fmt.Errorf("bridge not found")
+			if br := m.cfg.FindBridge(bridgeName); br != nil && br.DHCP != nil {
+				if err = validateDHCPOptions(next); err == nil {
+					br.DHCP.Options = next
+				}
+			}
+			m.cfg.Unlock()
+			if err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]invalid option:[-] %v", err))
+				return
+			}
+			if err := m.apply(); err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]apply failed:[-] %v", err))
+				return
+			}
+			_ = m.refresh()
+			m.redrawAll()
+			m.pages.HidePage("modal")
+		}
+
+		form.AddButton("Add", func() {
+			o := DHCPOption{ID: generateID(), Name: opt.Name, Value: normalizeOptionValue(opt.Value)}
+			if o.Name == "raw" {
+				o.Name = ""
+				o.Code, _ = strconv.Atoi(strings.TrimSpace(code))
+			}
+			save(append(append([]DHCPOption(nil), opts...), o))
+		})
+		form.AddButton("Close", func() { m.pages.HidePage("modal") })
+		form.SetCancelFunc(func() { m.pages.HidePage("modal") })
+
+		list.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+			if ev.Key() == tcell.KeyTab {
+				m.app.SetFocus(form)
+				return nil
+			}
+			row, _ := list.GetSelection()
+			if ev.Rune() != 'x' || row <= 0 || row-1 >= len(opts) {
+				return ev
+			}
+			next := append(append([]DHCPOption(nil), opts[:row-1]...), opts[row:]...)
+			save(next)
+			return nil
+		})
+
+		box := tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(list, 0, 1, len(opts) > 0).
+			AddItem(form, 11, 0, len(opts) == 0)
+		m.pages.AddAndSwitchToPage("modal", modal(box, 90, 24), true)
+		if len(opts) > 0 {
+			m.app.SetFocus(list)
+		} else {
+			m.app.SetFocus(form)
+		}
+	}
+
+	ipv6Form := func(b *BridgeConfig) {
+		form := tview.NewForm()
+		form.SetBorder(true).SetTitle("IPv6 on " + b.Name).SetTitleAlign(tview.AlignLeft)
+
+		var v6 IPv6Config
+		if b.IPv6 != nil {
+			v6 = *b.IPv6
+		}
+		modes := append([]string{"off"}, ipv6Modes...)
+		current := 0
+		for i, mode := range modes {
+			if mode == v6.Mode {
+				current = i
+			}
+		}
+		form.AddDropDown("Mode", modes, current, func(option string, _ int) { v6.Mode = option })
+		form.AddInputField("Prefix", v6.Prefix, 30, nil, func(text string) { v6.Prefix = strings.TrimSpace(text) })
+		form.AddInputField("Range start", v6.RangeStart, 30, nil, func(text string) { v6.RangeStart = strings.TrimSpace(text) })
+		form.AddInputField("Range end", v6.RangeEnd, 30, nil, func(text string) { v6.RangeEnd = strings.TrimSpace(text) })
+		form.AddInputField("Lease time", v6.LeaseTime, 10, nil, func(text string) { v6.LeaseTime = strings.TrimSpace(text) })
+
+		form.AddButton("Save", func() {
+			var next *IPv6Config
+			if v6.Mode != "off" {
+				cp := v6
+				if cp.Mode != "stateful" {
+					cp.RangeStart, cp.RangeEnd = "", ""
+				}
+				if err := validateIPv6Config(&cp); err != nil {
+					m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+					return
+				}
+				next = &cp
+			}
+			m.cfg.Lock()
+			if br := m.cfg.FindBridge(b.Name); br != nil {
+				br.IPv6 = next
+			}
+			m.cfg.Unlock()
+			if err := m.apply(); err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]apply failed:[-] %v", err))
+				return
+			}
+			_ = m.refresh()
+			m.redrawAll()
+			m.pages.HidePage("modal")
+		})
+		form.AddButton("Cancel", func() { m.pages.HidePage("modal") })
+		form.SetCancelFunc(func() { m.pages.HidePage("modal") })
+
+		m.pages.AddAndSwitchToPage("modal", modal(form, 70, 17), true)
 		m.app.SetFocus(form)
 	}
 
 	table.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
-		if ev.Rune() != 'e' {
+		if ev.Key() == tcell.KeyTab {
+			m.app.SetFocus(hosts)
+			return nil
+		}
+		if ev.Rune() != 'e' && ev.Rune() != 'h' && ev.Rune() != 'o' && ev.Rune() != '6' {
 			return ev
 		}
 		row, _ := table.GetSelection()
@@ -575,25 +1113,34 @@ func (m *TUIMode) dhcpPage() tview.Primitive {
 			return nil
 		}
 		b := m.cfg.Bridges[row-1]
-		editForm(&b)
+		switch ev.Rune() {
+		case 'h':
+			hostForm(b.Name, DHCPHost{})
+		case 'o':
+			optionsForm(b.Name)
+		case '6':
+			ipv6Form(&b)
+		default:
+			editForm(&b)
+		}
 		return nil
 	})
 
 	root.AddItem(table, 0, 2, true)
+	root.AddItem(hosts, 0, 1, false)
 	root.AddItem(leases, 0, 1, false)
 	return root
 }
 
 func (m *TUIMode) bridgesPage() tview.Primitive {
-	// Placeholder with proxmox bridges list (read-only for now).
 	table := tview.NewTable().SetBorders(false)
-	table.SetTitle("Proxmox Bridges (read-only in TUI v1)").SetBorder(true)
+	table.SetTitle("Proxmox Bridges (c=create, e=edit, n=renumber, x=delete, p=pending changes)").SetBorder(true)
 	table.SetFixed(1, 0)
 	table.SetSelectable(true, false)
 	table.Select(1, 0)
 	m.focus["Bridges"] = table
 
-	h := []string{"Name", "CIDR", "Ports", "Managed"}
+	h := []string{"Name", "CIDR", "Ports", "SDN Zone", "Managed"}
 	for i, s := range h {
 		table.SetCell(0, i, tview.NewTableCell(s).SetTextColor(tcell.ColorYellow))
 	}
@@ -601,16 +1148,547 @@ func (m *TUIMode) bridgesPage() tview.Primitive {
 		r := i + 1
 		table.SetCell(r, 0, tview.NewTableCell(b.Name))
 		table.SetCell(r, 1, tview.NewTableCell(b.CIDR))
-		table.SetCell(r, 2, tview.NewTableCell(b.Ports))
+		ports := b.Ports
+		switch {
+		case b.VLAN != 0:
+			ports = fmt.Sprintf("VLAN %d on %s", b.VLAN, b.Parent)
+		case b.Parent != "":
+			ports = "port of " + b.Parent
+		case b.VLANAware:
+			ports = strings.TrimSpace(ports + " (VLAN aware)")
+		}
+		if b.Type == ovsBridgeType || b.Type == ovsIntPortType {
+			ports = strings.TrimSpace(ports + " (OVS)")
+		}
+		table.SetCell(r, 2, tview.NewTableCell(ports))
+		table.SetCell(r, 3, tview.NewTableCell(b.Zone))
 		if b.Managed {
-			table.SetCell(r, 3, tview.NewTableCell("yes").SetTextColor(tcell.ColorGreen))
+			table.SetCell(r, 4, tview.NewTableCell("yes").SetTextColor(tcell.ColorGreen))
 		} else {
-			table.SetCell(r, 3, tview.NewTableCell("no").SetTextColor(tcell.ColorGray))
+			table.SetCell(r, 4, tview.NewTableCell("no").SetTextColor(tcell.ColorGray))
 		}
 	}
+
+	table.SetInputCapture(func(ev *tcell.EventKey) *tcell.EventKey {
+		switch ev.Rune() {
+		case 'c':
+			m.createBridgeForm()
+			return nil
+		case 'n':
+			row, _ := table.GetSelection()
+			if row <= 0 || row-1 >= len(m.pxBridges) || !m.pxBridges[row-1].Managed {
+				m.footer.SetText("[red]select a bridge managed by PNAT[-]")
+				return nil
+			}
+			m.renumberBridgeForm(m.pxBridges[row-1].Name)
+			return nil
+		case 'p':
+			m.networkChangesForm()
+			return nil
+		case 'e', 'x':
+			row, _ := table.GetSelection()
+			if row <= 0 || row-1 >= len(m.pxBridges) || m.pxBridges[row-1].Zone != "" {
+				m.footer.SetText("[red]select a Linux bridge (SDN VNets are managed in Proxmox SDN)[-]")
+				return nil
+			}
+			if ev.Rune() == 'e' {
+				if m.pxBridges[row-1].Parent != "" {
+					m.footer.SetText("[red]VLAN interfaces and OVS internal ports have no bridge settings to edit[-]")
+					return nil
+				}
+				m.editBridgeForm(m.pxBridges[row-1].Name)
+			} else {
+				m.deleteBridgeConfirm(m.pxBridges[row-1])
+			}
+			return nil
+		}
+		return ev
+	})
 	return table
 }
 
+// editBridgeForm edits the Proxmox settings of a bridge. The address of a
+// managed bridge is changed by renumbering it.
+func (m *TUIMode) editBridgeForm(name string) {
+	networks, err := m.px.ListNetworks()
+	if err != nil {
+		m.footer.SetText(fmt.Sprintf("[red]Proxmox API error:[-] %v", err))
+		return
+	}
+	var n *ProxmoxNetwork
+	for i := range networks {
+		if networks[i].Iface == name && isBridgeType(networks[i].Type) {
+			n = &networks[i]
+		}
+	}
+	if n == nil {
+		m.footer.SetText(fmt.Sprintf("[red]no Linux or OVS bridge %s on this node[-]", name))
+		return
+	}
+	cidr := n.CIDR
+	if cidr == "" && n.Address != "" && n.Netmask != "" {
+		cidr, _ = cidrFromAddrNetmask(n.Address, n.Netmask)
+	}
+	m.cfg.Lock()
+	locked := m.cfg.FindBridge(name) != nil || name == m.cfg.WanInterface
+	m.cfg.Unlock()
+
+	mtu, _ := n.MTU.Int64()
+	s := BridgeSettings{CIDR: cidr, Ports: n.Ports(), MTU: int(mtu), Comments: strings.TrimSpace(n.Comments), Autostart: n.Autostart == 1}
+	mtuText := ""
+	if s.MTU > 0 {
+		mtuText = strconv.Itoa(s.MTU)
+	}
+
+	form := tview.NewForm()
+	form.SetBorder(true).SetTitle("Edit " + name).SetTitleAlign(tview.AlignLeft)
+	if locked {
+		form.AddTextView("IPv4/CIDR", cidr+" (managed: use renumber)", 40, 1, true, false)
+	} else {
+		form.AddInputField("IPv4/CIDR", cidr, 20, nil, func(text string) { s.CIDR = strings.TrimSpace(text) })
+	}
+	form.AddInputField("Bridge ports", s.Ports, 30, nil, func(text string) { s.Ports = strings.Join(strings.Fields(text), " ") })
+	form.AddInputField("MTU", mtuText, 6, nil, func(text string) { mtuText = strings.TrimSpace(text) })
+	form.AddInputField("Comment", s.Comments, 40, nil, func(text string) { s.Comments = strings.TrimSpace(text) })
+	form.AddCheckbox("Autostart", s.Autostart, func(checked bool) { s.Autostart = checked })
+	form.AddButton("Save", func() {
+		s.MTU = 0
+		if mtuText != "" {
+			v, err := strconv.Atoi(mtuText)
+			if err != nil {
+				m.footer.SetText("[red]invalid MTU[-]")
+				return
+			}
+			s.MTU = v
+		}
+		if err := validateBridgeSettings(s); err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+			return
+		}
+		if !locked && s.CIDR != "" && s.CIDR != cidr {
+			subnet, err := subnetFromCIDR(s.CIDR)
+			if err != nil {
+				m.footer.SetText("[red]invalid CIDR (expected IPv4 address/prefix)[-]")
+				return
+			}
+			m.cfg.Lock()
+			err = m.cfg.checkSubnet(name, subnet)
+			m.cfg.Unlock()
+			if err == nil {
+				err = checkBridgeOverlap(name, subnet, m.pxBridges)
+			}
+			if err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+				return
+			}
+		}
+		if err := m.px.UpdateBridge(name, s); err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]Proxmox API error:[-] %v", err))
+			return
+		}
+		m.networkChangesForm()
+	})
+	form.AddButton("Cancel", func() { m.pages.HidePage("modal") })
+	form.SetCancelFunc(func() { m.pages.HidePage("modal") })
+
+	m.pages.AddAndSwitchToPage("modal", modal(form, 70, 17), true)
+	m.app.SetFocus(form)
+}
+
+// deleteBridgeConfirm stages the deletion of an unused bridge in Proxmox after
+// confirmation and shows the pending network changes; a managed bridge is
+// detached from PNAT once they are applied.
+func (m *TUIMode) deleteBridgeConfirm(b BridgeView) {
+	text := fmt.Sprintf("Delete %s in Proxmox?", b.Name)
+	if b.Managed {
+		text = fmt.Sprintf("Detach %s from PNAT and delete it in Proxmox?\nNAT, forwards and DHCP for it are removed.", b.Name)
+	}
+	dlg := tview.NewModal().SetText(text).AddButtons([]string{"Delete", "Cancel"})
+	dlg.SetDoneFunc(func(_ int, label string) {
+		m.pages.HidePage("modal")
+		if label != "Delete" {
+			return
+		}
+		if err := deleteBridge(m.px, m.cfg.WanInterface, b.Name); err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+			return
+		}
+		if err := m.network.StageDelete(b.Name); err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+			return
+		}
+		m.networkChangesForm()
+	})
+	m.pages.AddAndSwitchToPage("modal", dlg, true)
+	m.app.SetFocus(dlg)
+}
+
+// renumberBridgeForm previews and applies moving a managed bridge to a new
+// subnet, like /bridges/renumber/<bridge> in the web UI.
+func (m *TUIMode) renumberBridgeForm(name string) {
+	static := staticGuestIPs(m.vmViews, name)
+	subnet := ""
+	m.cfg.Lock()
+	if next, err := m.cfg.NextFreeSubnet(unmanagedBridgeCIDRs(m.pxBridges)); err == nil {
+		subnet = next.Subnet
+	}
+	m.cfg.Unlock()
+
+	preview := tview.NewTextView().SetDynamicColors(true).SetWrap(true)
+	preview.SetBorder(true).SetTitle("Preview")
+
+	plan := func() (*RenumberPlan, error) {
+		m.cfg.Lock()
+		defer m.cfg.Unlock()
+		p, err := m.cfg.PlanRenumber(name, subnet, static)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkBridgeOverlap(name, p.NewSubnet, m.pxBridges); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	showPlan := func() {
+		p, err := plan()
+		if err != nil {
+			preview.SetText(fmt.Sprintf("[red]%v[-]", tview.Escape(err.Error())))
+			return
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "%s -> %s, bridge address %s -> %s\n\n", p.OldSubnet, p.NewSubnet, p.OldCIDR, p.NewCIDR)
+		for _, c := range p.Changes {
+			fmt.Fprintf(&b, "%-40s %15s -> %s\n", tview.Escape(c.Item), c.Old, c.New)
+		}
+		for _, w := range p.Warnings {
+			fmt.Fprintf(&b, "[yellow]%s[-]\n", tview.Escape(w))
+		}
+		preview.SetText(b.String())
+	}
+
+	form := tview.NewForm()
+	form.SetBorder(true).SetTitle("Renumber " + name).SetTitleAlign(tview.AlignLeft)
+	form.AddInputField("New subnet", subnet, 18, nil, func(text string) { subnet = strings.TrimSpace(text) })
+	form.AddButton("Preview", showPlan)
+	form.AddButton("Apply", func() {
+		p, err := plan()
+		if err == nil {
+			err = stageRenumber(m.px, m.network, m.cfg.BridgeNodes, p)
+		}
+		if err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+			return
+		}
+		m.networkChangesForm()
+	})
+	form.AddButton("Cancel", func() { m.pages.HidePage("modal") })
+	form.SetCancelFunc(func() { m.pages.HidePage("modal") })
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(form, 7, 0, true).
+		AddItem(preview, 0, 1, false)
+	m.pages.AddAndSwitchToPage("modal", modal(layout, 100, 30), true)
+	m.app.SetFocus(form)
+}
+
+// createBridgeForm creates a Proxmox bridge managed by PNAT, prefilled with
+// the next free subnet of the subnet pool.
+func (m *TUIMode) createBridgeForm() {
+	m.cfg.Lock()
+	next, err := m.cfg.NextFreeSubnet(unmanagedBridgeCIDRs(m.pxBridges))
+	m.cfg.Unlock()
+	if err != nil {
+		m.footer.SetText(fmt.Sprintf("[yellow]no subnet suggestion:[-] %v", err))
+	}
+
+	name, ports, zone, vlanStr := "", "", "", ""
+	typ, ovsBridge := "bridge", ""
+	subnet, gateway := next.Subnet, next.GatewayIP
+	rangeStart, rangeEnd := next.RangeStart, next.RangeEnd
+	natEnabled, dhcpEnabled := true, true
+
+	form := tview.NewForm()
+	form.SetBorder(true).SetTitle("Create Bridge").SetTitleAlign(tview.AlignLeft)
+	form.AddInputField("Name", name, 21, nil, func(text string) { name = strings.TrimSpace(text) })
+	form.AddDropDown("Type", []string{"bridge", ovsBridgeType, ovsIntPortType}, 0, func(option string, _ int) { typ = option })
+	form.AddInputField("Bridge ports", ports, 21, nil, func(text string) { ports = strings.TrimSpace(text) })
+	form.AddInputField("OVS bridge (internal port)", ovsBridge, 21, nil, func(text string) { ovsBridge = strings.TrimSpace(text) })
+	form.AddInputField("SDN zone (VNet)", zone, 8, nil, func(text string) { zone = strings.TrimSpace(text) })
+	form.AddInputField("VLAN (bridge VLAN or port tag)", vlanStr, 5, nil, func(text string) { vlanStr = strings.TrimSpace(text) })
+	form.AddInputField("Subnet", subnet, 18, nil, func(text string) { subnet = strings.TrimSpace(text) })
+	form.AddInputField("Gateway IP", gateway, 15, nil, func(text string) { gateway = strings.TrimSpace(text) })
+	form.AddCheckbox("Enable NAT", natEnabled, func(checked bool) { natEnabled = checked })
+	form.AddCheckbox("Enable DHCP", dhcpEnabled, func(checked bool) { dhcpEnabled = checked })
+	form.AddInputField("Range start", rangeStart, 15, nil, func(text string) { rangeStart = strings.TrimSpace(text) })
+	form.AddInputField("Range end", rangeEnd, 15, nil, func(text string) { rangeEnd = strings.TrimSpace(text) })
+
+	form.AddButton("Create", func() {
+		if !ifaceNameRe.MatchString(name) {
+			m.footer.SetText("[red]invalid bridge name[-]")
+			return
+		}
+		if zone != "" && (!sdnIDRe.MatchString(zone) || !sdnIDRe.MatchString(name) || ports != "") {
+			m.footer.SetText("[red]SDN zone and VNet: lowercase letters and digits, 2-8 characters, no bridge ports[-]")
+			return
+		}
+		vlan, tag, iface := 0, 0, name
+		if vlanStr != "" {
+			var err error
+			if vlan, err = strconv.Atoi(vlanStr); err != nil || !validVLAN(vlan) {
+				m.footer.SetText("[red]VLAN must be between 1 and 4094[-]")
+				return
+			}
+		}
+		switch typ {
+		case ovsBridgeType:
+			if zone != "" || vlan != 0 {
+				m.footer.SetText("[red]an OVS bridge has no SDN zone or VLAN; create an OVS internal port for a VLAN[-]")
+				return
+			}
+		case ovsIntPortType:
+			// The VLAN is the tag of the port on its OVS bridge.
+			if zone != "" || ports != "" {
+				m.footer.SetText("[red]an OVS internal port has no SDN zone or bridge ports[-]")
+				return
+			}
+			if err := checkOVSBridge(m.px, ovsBridge); err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+				return
+			}
+			tag, vlan = vlan, 0
+		default:
+			if vlan == 0 {
+				break
+			}
+			// With a VLAN, name is the VLAN-aware bridge and the network
+			// is its VLAN interface.
+			if zone != "" || ports != "" {
+				m.footer.SetText("[red]a VLAN network has no SDN zone or bridge ports[-]")
+				return
+			}
+			if err := checkVLANBridge(m.px, name); err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+				return
+			}
+			iface = vlanNetworkName(name, vlan)
+		}
+		cidr, err := cidrFromSubnetAndGateway(subnet, gateway)
+		if err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]invalid subnet/gateway:[-] %v", err))
+			return
+		}
+		if normalized, err := subnetFromCIDR(subnet); err == nil {
+			subnet = normalized
+		}
+		m.cfg.Lock()
+		exists := m.cfg.FindBridge(iface) != nil || m.network.IsStaged(iface)
+		err = m.cfg.checkSubnet(iface, subnet)
+		m.cfg.Unlock()
+		if exists {
+			m.footer.SetText("[red]bridge already managed by PNAT[-]")
+			return
+		}
+		if err == nil {
+			err = checkBridgeOverlap(iface, subnet, m.pxBridges)
+		}
+		if err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+			return
+		}
+		if dhcpEnabled {
+			if err := validateDHCPRange(subnet, gateway, rangeStart, rangeEnd); err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+				return
+			}
+		}
+
+		br := BridgeConfig{Name: iface, Subnet: subnet, GatewayIP: gateway, SDNZone: zone, VLAN: vlan, NATEnabled: natEnabled}
+		if dhcpEnabled {
+			br.DHCP = &DHCPConfig{RangeStart: rangeStart, RangeEnd: rangeEnd, LeaseTime: "12h"}
+		}
+		if zone == "" {
+			// The network is managed once the staged change is applied.
+			create := func() error { return m.px.CreateBridge(name, cidr, ports) }
+			switch {
+			case vlan != 0:
+				create = func() error { return m.px.CreateVLAN(iface, cidr) }
+			case typ == ovsBridgeType:
+				create = func() error { return m.px.CreateOVSBridge(name, cidr, ports) }
+			case typ == ovsIntPortType:
+				create = func() error { return m.px.CreateOVSIntPort(name, ovsBridge, tag, cidr) }
+			}
+			if err := create(); err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]Proxmox API error:[-] %v", err))
+				return
+			}
+			if err := m.network.Stage(br); err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+				return
+			}
+			m.networkChangesForm()
+			return
+		}
+		if err := createSDNVNet(m.px, zone, name, subnet, gateway); err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]Proxmox SDN error:[-] %v", err))
+			return
+		}
+
+		m.cfg.Lock()
+		m.cfg.Bridges = append(m.cfg.Bridges, br)
+		m.cfg.Unlock()
+		if err := m.apply(); err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]apply failed:[-] %v", err))
+			return
+		}
+		_ = m.refresh()
+		m.redrawAll()
+		m.pages.HidePage("modal")
+	})
+	form.AddButton("Cancel", func() { m.pages.HidePage("modal") })
+	form.SetCancelFunc(func() { m.pages.HidePage("modal") })
+
+	m.pages.AddAndSwitchToPage("modal", modal(form, 70, 30), true)
+	m.app.SetFocus(form)
+}
+
+// networkChangesForm shows the network changes staged in Proxmox with
+// Apply and Revert, or the confirmation of applied ones.
+func (m *TUIMode) networkChangesForm() {
+	status, err := m.network.Pending(m.px)
+	if err != nil {
+		m.footer.SetText(fmt.Sprintf("[red]Proxmox API error:[-] %v", err))
+		return
+	}
+	if status.Awaiting() {
+		m.confirmNetworkModal()
+		return
+	}
+	if status.Diff == "" && len(status.Staged) == 0 && len(status.Deleted) == 0 {
+		m.pages.HidePage("modal")
+		m.footer.SetText("no pending network changes")
+		return
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(status.Diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+"):
+			fmt.Fprintf(&b, "[green]%s[-]\n", tview.Escape(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Fprintf(&b, "[red]%s[-]\n", tview.Escape(line))
+		default:
+			fmt.Fprintf(&b, "%s\n", tview.Escape(line))
+		}
+	}
+	if len(status.Staged) > 0 {
+		fmt.Fprintf(&b, "\n[yellow]Managed by PNAT once applied:[-] %s\n", strings.Join(status.Staged, ", "))
+	}
+	if len(status.Deleted) > 0 {
+		fmt.Fprintf(&b, "\n[yellow]Deleted once applied:[-] %s\n", strings.Join(status.Deleted, ", "))
+	}
+	diff := tview.NewTextView().SetDynamicColors(true).SetText(b.String())
+	diff.SetBorder(true).SetTitle("/etc/network/interfaces")
+
+	timeout := strconv.Itoa(int(defaultNetworkConfirm.Seconds()))
+	form := tview.NewForm()
+	form.SetBorder(true).SetTitle("Pending Network Changes").SetTitleAlign(tview.AlignLeft)
+	form.AddInputField("Revert unless confirmed (s, 0 = off)", timeout, 5, nil, func(text string) { timeout = text })
+	form.AddButton("Apply", func() {
+		t, err := parseConfirmTimeout(timeout)
+		if err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+			return
+		}
+		if err := m.network.Apply(m.px, t); err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]%v[-]", err))
+			return
+		}
+		_ = m.refresh()
+		m.redrawAll()
+		if t > 0 {
+			m.confirmNetworkModal()
+			return
+		}
+		m.pages.HidePage("modal")
+	})
+	form.AddButton("Revert", func() {
+		if err := m.network.Revert(m.px); err != nil {
+			m.footer.SetText(fmt.Sprintf("[red]revert failed:[-] %v", err))
+			return
+		}
+		_ = m.refresh()
+		m.redrawAll()
+		m.pages.HidePage("modal")
+	})
+	form.AddButton("Cancel", func() { m.pages.HidePage("modal") })
+	form.SetCancelFunc(func() { m.pages.HidePage("modal") })
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(form, 7, 0, true).
+		AddItem(diff, 0, 1, false)
+	m.pages.AddAndSwitchToPage("modal", modal(layout, 90, 30), true)
+	m.app.SetFocus(form)
+}
+
+// confirmNetworkModal counts down to the automatic revert of applied network
+// changes until they are confirmed or reverted.
+func (m *TUIMode) confirmNetworkModal() {
+	done := make(chan struct{})
+	dlg := tview.NewModal().AddButtons([]string{"Keep changes", "Revert now"})
+	text := func(deadline time.Time) string {
+		return fmt.Sprintf("The network was reloaded.\nThe previous configuration is restored in %ds unless you confirm.", int(time.Until(deadline).Seconds()+0.5))
+	}
+	dlg.SetText(text(m.network.Status().Deadline))
+	dlg.SetDoneFunc(func(_ int, label string) {
+		close(done)
+		m.pages.HidePage("modal")
+		switch label {
+		case "Keep changes":
+			if !m.network.Confirm() {
+				m.footer.SetText("[red]the network changes have already been reverted[-]")
+				return
+			}
+			m.footer.SetText("[green]network changes confirmed[-]")
+		case "Revert now":
+			if err := m.network.Revert(m.px); err != nil {
+				m.footer.SetText(fmt.Sprintf("[red]revert failed:[-] %v", err))
+			}
+			_ = m.refresh()
+			m.redrawAll()
+		}
+	})
+	go func() {
+		tick := time.NewTicker(time.Second)
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-tick.C:
+			}
+			status := m.network.Status()
+			m.app.QueueUpdateDraw(func() {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if status.Awaiting() {
+					dlg.SetText(text(status.Deadline))
+					return
+				}
+				close(done)
+				m.pages.HidePage("modal")
+				_ = m.refresh()
+				m.redrawAll()
+				m.footer.SetText(fmt.Sprintf("[red]%s[-]", tview.Escape(status.Reverted)))
+			})
+		}
+	}()
+	m.pages.AddAndSwitchToPage("modal", dlg, true)
+	m.app.SetFocus(dlg)
+}
+
 func (m *TUIMode) vmsPage() tview.Primitive {
 	table := tview.NewTable().SetBorders(false)
 	table.SetTitle("VMs (read-only in TUI v1)").SetBorder(true)
@@ -619,7 +1697,7 @@ func (m *TUIMode) vmsPage() tview.Primitive {
 	table.Select(1, 0)
 	m.focus["VMs"] = table
 
-	h := []string{"VMID", "Name", "Type", "Status", "NICs"}
+	h := []string{"VMID", "Name", "Type", "Status", "Node", "NICs"}
 	for i, s := range h {
 		table.SetCell(0, i, tview.NewTableCell(s).SetTextColor(tcell.ColorYellow))
 	}
@@ -629,15 +1707,26 @@ func (m *TUIMode) vmsPage() tview.Primitive {
 		table.SetCell(r, 1, tview.NewTableCell(vm.Name))
 		table.SetCell(r, 2, tview.NewTableCell(vm.Type))
 		table.SetCell(r, 3, tview.NewTableCell(vm.Status))
+		if vm.Local {
+			table.SetCell(r, 4, tview.NewTableCell(vm.Node))
+		} else {
+			table.SetCell(r, 4, tview.NewTableCell(vm.Node).SetTextColor(tcell.ColorOrange))
+		}
 		var nics []string
 		for _, n := range vm.NICs {
 			ip := n.LeaseIP
 			if ip == "" && len(n.IPs) > 0 {
 				ip = n.IPs[0]
 			}
-			nics = append(nics, fmt.Sprintf("%s:%s %s", n.Key, n.Bridge, ip))
+			if n.LeaseIP6 != "" {
+				ip = strings.TrimSpace(ip + " " + n.LeaseIP6)
+			}
+			if len(n.AgentIPs) > 0 {
+				ip = strings.TrimSpace(ip + " agent:" + strings.Join(n.AgentIPs, ","))
+			}
+			nics = append(nics, fmt.Sprintf("%s:%s %s", n.Key, n.Network, ip))
 		}
-		table.SetCell(r, 4, tview.NewTableCell(strings.Join(nics, " | ")))
+		table.SetCell(r, 5, tview.NewTableCell(strings.Join(nics, " | ")))
 	}
 	return table
 }
@@ -717,3 +1806,12 @@ func modal(p tview.Primitive, width, height int) tview.Primitive {
 			width, 1, true).
 		AddItem(nil, 0, 1, false)
 }
+
+// dhcpRangeText shows the first DHCP range and how many more there are.
+func dhcpRangeText(d *DHCPConfig) string {
+	s := fmt.Sprintf("%s-%s", d.RangeStart, d.RangeEnd)
+	if len(d.Ranges) > 0 {
+		s += fmt.Sprintf(" +%d", len(d.Ranges))
+	}
+	return s
+}
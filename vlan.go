@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A VLAN network is a VLAN on a VLAN-aware bridge: guests join it with their
+// NIC on the bridge and tag=<vlan>, and the host's gateway on it is the VLAN
+// interface <bridge>.<vlan> (e.g. vmbr0.100), which PNAT manages like a
+// bridge for NAT, forwards and DHCP.
+
+var vlanIfaceRe = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9_]{1,20})\.(\d{1,4})$`)
+
+// vlanNetworkName returns the interface of VLAN tag on bridge, or the bridge
+// itself when untagged.
+func vlanNetworkName(bridge string, tag int) string {
+	if tag == 0 {
+		return bridge
+	}
+	return fmt.Sprintf("%s.%d", bridge, tag)
+}
+
+// splitVLANNetwork returns the bridge and VLAN tag of a network interface
+// name; the tag is 0 for a plain bridge.
+func splitVLANNetwork(name string) (string, int) {
+	m := vlanIfaceRe.FindStringSubmatch(name)
+	if m == nil {
+		return name, 0
+	}
+	tag, _ := strconv.Atoi(m[2])
+	if !validVLAN(tag) {
+		return name, 0
+	}
+	return m[1], tag
+}
+
+func validVLAN(tag int) bool {
+	return tag >= 1 && tag <= 4094
+}
+
+// checkVLANBridge makes sure bridge is a VLAN-aware Linux bridge on this node,
+// so a VLAN interface on it reaches guests tagged with that VLAN.
+func checkVLANBridge(px *ProxmoxClient, bridge string) error {
+	networks, err := px.ListNetworks()
+	if err != nil {
+		return fmt.Errorf("list networks: %w", err)
+	}
+	for _, n := range networks {
+		if n.Iface != bridge {
+			continue
+		}
+		if n.Type == ovsBridgeType {
+			return fmt.Errorf("%s is an OVS bridge; create an OVS internal port with the VLAN as its tag instead", bridge)
+		}
+		if n.Type != "bridge" {
+			return fmt.Errorf("%s is not a Linux bridge", bridge)
+		}
+		if n.VLANAware != 1 {
+			return fmt.Errorf("bridge %s is not VLAN aware; enable \"VLAN aware\" on it in Proxmox first", bridge)
+		}
+		return nil
+	}
+	return fmt.Errorf("no Linux bridge %s on this node", bridge)
+}
+
+// validateVLANNetwork checks that a managed network with a VLAN is named
+// after its bridge and tag.
+func validateVLANNetwork(b BridgeConfig) error {
+	if b.VLAN == 0 {
+		return nil
+	}
+	if !validVLAN(b.VLAN) {
+		return fmt.Errorf("invalid vlan %d (1-4094)", b.VLAN)
+	}
+	if b.SDNZone != "" {
+		return fmt.Errorf("SDN VNets cannot have a vlan")
+	}
+	if _, tag := splitVLANNetwork(b.Name); tag != b.VLAN {
+		return fmt.Errorf("name must be <bridge>.%d for vlan %d", b.VLAN, b.VLAN)
+	}
+	return nil
+}
+
+// setNICNetwork points a guest NIC config string at bridge, tagged with tag
+// or untagged when it is 0.
+func setNICNetwork(val, bridge string, tag int) string {
+	parts := splitCommaKV(val)
+	if len(parts) == 0 {
+		return val
+	}
+	out := parts[:0]
+	hasBridge := false
+	for _, p := range parts {
+		switch k, _ := parseFirstKV(p); k {
+		case "bridge":
+			p = "bridge=" + bridge
+			hasBridge = true
+		case "tag":
+			continue
+		}
+		out = append(out, p)
+	}
+	if !hasBridge {
+		out = append(out, "bridge="+bridge)
+	}
+	if tag != 0 {
+		out = append(out, "tag="+strconv.Itoa(tag))
+	}
+	return strings.Join(out, ",")
+}
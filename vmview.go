@@ -5,7 +5,9 @@ import (
 	"log"
 	"net"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -14,9 +16,15 @@ type VMNICView struct {
 	Model     string
 	MAC       string
 	Bridge    string
+	Tag       int    // VLAN tag, 0 if untagged
+	Network   string // network the NIC is on: Bridge, <bridge>.<tag> when tagged, or an OVS internal port
 	IPs       []string
+	StaticIP  string // LXC ip= address without prefix length
 	LeaseIP   string
+	LeaseIP6  string
 	LeaseHost string
+	AgentIPs  []string // reported by the QEMU guest agent, with prefix length
+	Reserved  bool
 }
 
 type VMView struct {
@@ -24,7 +32,12 @@ type VMView struct {
 	Name   string
 	Type   string
 	Status string
+	Node   string
+	Local  bool
 	NICs   []VMNICView
+	// Incomplete is set when the guest config or the host networks could
+	// not be read: NICs may then be missing or on the wrong network.
+	Incomplete bool
 }
 
 type UsedIP struct {
@@ -41,6 +54,17 @@ type BridgeUsedIPs struct {
 	IPs    []UsedIP
 }
 
+type LeaseView struct {
+	Lease
+	Bridge    string       `json:"bridge,omitempty"`
+	Remaining string       `json:"expires_in"` // "3h12m0s", "infinite" or "expired"
+	Reserved  bool         `json:"reserved"`
+	VMID      int          `json:"vmid,omitempty"`
+	VMName    string       `json:"vm_name,omitempty"`
+	NICKey    string       `json:"nic,omitempty"`
+	History   *LeaseRecord `json:"history,omitempty"`
+}
+
 type BridgeIPOption struct {
 	IP    string
 	Label string
@@ -103,11 +127,14 @@ func parseQemuNIC(key, val string) (VMNICView, bool) {
 		mac = ""
 	}
 	br := kvGet(parts, "bridge")
+	tag, _ := strconv.Atoi(kvGet(parts, "tag"))
 	return VMNICView{
-		Key:    key,
-		Model:  model,
-		MAC:    mac,
-		Bridge: br,
+		Key:     key,
+		Model:   model,
+		MAC:     mac,
+		Bridge:  br,
+		Tag:     tag,
+		Network: vlanNetworkName(br, tag),
 	}, true
 }
 
@@ -120,45 +147,32 @@ func parseLXCNIC(key, val string) (VMNICView, bool) {
 		return VMNICView{}, false
 	}
 	br := kvGet(parts, "bridge")
+	tag, _ := strconv.Atoi(kvGet(parts, "tag"))
 	mac := kvGet(parts, "hwaddr")
 	name := kvGet(parts, "name")
 	ip := kvGet(parts, "ip")
 	var ips []string
+	static := ""
 	if ip != "" && ip != "dhcp" {
 		ips = append(ips, ip)
+		static = strings.Split(ip, "/")[0]
 	}
 	model := "lxc"
 	if name != "" {
 		model = name
 	}
 	return VMNICView{
-		Key:    key,
-		Model:  model,
-		MAC:    mac,
-		Bridge: br,
-		IPs:    ips,
+		Key:      key,
+		Model:    model,
+		MAC:      mac,
+		Bridge:   br,
+		Tag:      tag,
+		Network:  vlanNetworkName(br, tag),
+		IPs:      ips,
+		StaticIP: static,
 	}, true
 }
 
-func updateBridgeInNetString(val, newBridge string) string {
-	parts := splitCommaKV(val)
-	if len(parts) == 0 {
-		return val
-	}
-	updated := false
-	for i, p := range parts {
-		k, _ := parseFirstKV(p)
-		if k == "bridge" {
-			parts[i] = "bridge=" + newBridge
-			updated = true
-		}
-	}
-	if !updated {
-		parts = append(parts, "bridge="+newBridge)
-	}
-	return strings.Join(parts, ",")
-}
-
 func (app *App) buildBridgeNameOptions(proxmoxBridges []BridgeView) []string {
 	var names []string
 	for _, b := range proxmoxBridges {
@@ -170,23 +184,31 @@ func (app *App) buildBridgeNameOptions(proxmoxBridges []BridgeView) []string {
 
 func buildVMViews(px *ProxmoxClient, vms []VM, leases []Lease) []VMView {
 	leaseByMAC := make(map[string]Lease, len(leases))
+	lease6ByMAC := make(map[string]Lease)
 	for _, l := range leases {
 		m := normalizeMAC(l.MAC)
 		if m == "" {
 			continue
 		}
-		leaseByMAC[m] = l
+		if l.IsIPv6() {
+			lease6ByMAC[m] = l
+		} else {
+			leaseByMAC[m] = l
+		}
 	}
 
+	details := fetchGuestDetails(px, vms)
+	networks, err := px.ListNetworks()
+	if err != nil {
+		log.Printf("WARN: failed to list networks: %v", err)
+	}
+	ovsPorts := ovsPortNetworks(networks)
 	var out []VMView
-	for _, vm := range vms {
-		view := VMView{VMID: vm.VMID, Name: vm.Name, Type: vm.Type, Status: vm.Status}
+	for i, vm := range vms {
+		view := VMView{VMID: vm.VMID, Name: vm.Name, Type: vm.Type, Status: vm.Status, Node: vm.Node, Local: vm.Local}
+		view.Incomplete = details[i].err != nil || err != nil
 
-		cfg, err := px.GetVMConfig(vm.Type, vm.VMID)
-		if err != nil {
-			log.Printf("WARN: failed to get VM config %s/%d: %v", vm.Type, vm.VMID, err)
-		}
-		for k, v := range cfg {
+		for k, v := range details[i].config {
 			if !netKeyRe.MatchString(k) {
 				continue
 			}
@@ -200,6 +222,7 @@ func buildVMViews(px *ProxmoxClient, vms []VM, leases []Lease) []VMView {
 			if !ok {
 				continue
 			}
+			nic.Network = nicNetwork(ovsPorts, nic.Bridge, nic.Tag)
 			if nic.MAC != "" {
 				if l, ok := leaseByMAC[normalizeMAC(nic.MAC)]; ok {
 					nic.LeaseIP = l.IP
@@ -208,6 +231,10 @@ func buildVMViews(px *ProxmoxClient, vms []VM, leases []Lease) []VMView {
 						nic.IPs = append(nic.IPs, l.IP)
 					}
 				}
+				if l, ok := lease6ByMAC[normalizeMAC(nic.MAC)]; ok {
+					nic.LeaseIP6 = l.IP
+				}
+				nic.AgentIPs = details[i].agentIPs[normalizeMAC(nic.MAC)]
 			}
 			view.NICs = append(view.NICs, nic)
 		}
@@ -218,6 +245,28 @@ func buildVMViews(px *ProxmoxClient, vms []VM, leases []Lease) []VMView {
 	return out
 }
 
+// staticGuestIPs maps the static IPv4 addresses of guest NICs on bridge to a
+// description of the NIC, e.g. "CT 101 (web) net0".
+func staticGuestIPs(vms []VMView, bridge string) map[string]string {
+	out := map[string]string{}
+	for _, vm := range vms {
+		for _, nic := range vm.NICs {
+			if nic.Network != bridge || nic.StaticIP == "" {
+				continue
+			}
+			if _, err := parseIPv4(nic.StaticIP); err != nil {
+				continue
+			}
+			who := fmt.Sprintf("CT %d %s", vm.VMID, nic.Key)
+			if vm.Name != "" {
+				who = fmt.Sprintf("CT %d (%s) %s", vm.VMID, vm.Name, nic.Key)
+			}
+			out[nic.StaticIP] = who
+		}
+	}
+	return out
+}
+
 func buildUsedIPs(cfg *Config, leases []Lease, vms []VMView) []BridgeUsedIPs {
 	bridgeBySubnet := make(map[string]*BridgeUsedIPs)
 	for _, b := range cfg.Bridges {
@@ -262,28 +311,36 @@ func buildUsedIPs(cfg *Config, leases []Lease, vms []VMView) []BridgeUsedIPs {
 		}
 	}
 
-	// VM IPs (best-effort: DHCP lease match + LXC static ip=).
+	// VM IPs (best-effort: DHCP lease match + LXC static ip= + guest agent).
+	addVMIP := func(vm VMView, nic VMNICView, ip, source string) {
+		ip4 := net.ParseIP(strings.Split(ip, "/")[0]).To4()
+		if ip4 == nil {
+			return
+		}
+		for _, b := range cfg.Bridges {
+			_, ipnet, err := net.ParseCIDR(b.Subnet)
+			if err != nil {
+				continue
+			}
+			if ipnet.Contains(ip4) {
+				bridgeBySubnet[b.Name].IPs = append(bridgeBySubnet[b.Name].IPs, UsedIP{
+					IP:     ip4.String(),
+					Source: source,
+					VMID:   vm.VMID,
+					VMName: vm.Name,
+					MAC:    nic.MAC,
+				})
+			}
+		}
+	}
 	for _, vm := range vms {
 		for _, nic := range vm.NICs {
 			for _, ip := range nic.IPs {
-				ip4 := net.ParseIP(strings.Split(ip, "/")[0]).To4()
-				if ip4 == nil {
-					continue
-				}
-				for _, b := range cfg.Bridges {
-					_, ipnet, err := net.ParseCIDR(b.Subnet)
-					if err != nil {
-						continue
-					}
-					if ipnet.Contains(ip4) {
-						bridgeBySubnet[b.Name].IPs = append(bridgeBySubnet[b.Name].IPs, UsedIP{
-							IP:     strings.Split(ip, "/")[0],
-							Source: "vm",
-							VMID:   vm.VMID,
-							VMName: vm.Name,
-							MAC:    nic.MAC,
-						})
-					}
+				addVMIP(vm, nic, ip, "vm")
+			}
+			for _, ip := range nic.AgentIPs {
+				if strings.Split(ip, "/")[0] != nic.LeaseIP {
+					addVMIP(vm, nic, ip, "agent")
 				}
 			}
 		}
@@ -323,6 +380,208 @@ func validateNetKey(key string) error {
 	return nil
 }
 
+// qemuNICModels are the QEMU NIC models offered when adding or editing a NIC.
+var qemuNICModels = []string{
+	"virtio", "e1000", "e1000e", "rtl8139", "vmxnet3",
+	"e1000-82540em", "e1000-82544gc", "e1000-82545em",
+	"i82551", "i82557b", "i82559er", "ne2k_isa", "ne2k_pci", "pcnet",
+}
+
+// lxcNICNameRe matches a network interface name inside a container.
+var lxcNICNameRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_.-]{0,14}$`)
+
+// NICSpec is a guest NIC as edited in PNAT. Model applies to QEMU; Name, IP
+// and GW apply to LXC; the rest to both.
+type NICSpec struct {
+	Model    string // QEMU: virtio, e1000, ...
+	Name     string // LXC: interface name inside the container, e.g. eth0
+	MAC      string // empty: Proxmox generates one
+	Bridge   string
+	Tag      int    // VLAN tag; 0 = untagged
+	Firewall bool   // Proxmox firewall on the NIC
+	Rate     string // rate limit in MB/s; empty = unlimited
+	IP       string // LXC: "dhcp", "manual" or an IPv4 CIDR
+	GW       string // LXC: gateway for a static IP
+}
+
+// parseNICSpec reads the editable fields of a netN value.
+func parseNICSpec(vmType, val string) NICSpec {
+	parts := splitCommaKV(val)
+	var spec NICSpec
+	if vmType == "qemu" && len(parts) > 0 {
+		if model, mac := parseFirstKV(parts[0]); model != "" {
+			spec.Model, spec.MAC = model, mac
+		} else {
+			spec.Model = parts[0]
+		}
+	} else {
+		spec.Name = kvGet(parts, "name")
+		spec.MAC = kvGet(parts, "hwaddr")
+		spec.IP = kvGet(parts, "ip")
+		spec.GW = kvGet(parts, "gw")
+	}
+	spec.Bridge = kvGet(parts, "bridge")
+	spec.Tag, _ = strconv.Atoi(kvGet(parts, "tag"))
+	spec.Firewall = kvGet(parts, "firewall") == "1"
+	spec.Rate = kvGet(parts, "rate")
+	return spec
+}
+
+// validate checks and normalizes spec for a guest of vmType.
+func (s *NICSpec) validate(vmType string) error {
+	if !ifaceNameRe.MatchString(s.Bridge) {
+		return fmt.Errorf("invalid bridge name %q", s.Bridge)
+	}
+	if s.MAC != "" {
+		hw, err := net.ParseMAC(s.MAC)
+		if err != nil || len(hw) != 6 {
+			return fmt.Errorf("invalid MAC address %q", s.MAC)
+		}
+		if hw[0]&1 != 0 {
+			return fmt.Errorf("MAC address %s is a multicast address", s.MAC)
+		}
+		s.MAC = strings.ToUpper(hw.String())
+	}
+	if s.Tag < 0 || s.Tag > 4094 {
+		return fmt.Errorf("VLAN tag must be between 1 and 4094")
+	}
+	if s.Rate != "" {
+		rate, err := strconv.ParseFloat(s.Rate, 64)
+		if err != nil || rate <= 0 {
+			return fmt.Errorf("invalid rate limit %q (MB/s)", s.Rate)
+		}
+	}
+	switch vmType {
+	case "qemu":
+		if !slices.Contains(qemuNICModels, s.Model) {
+			return fmt.Errorf("invalid NIC model %q", s.Model)
+		}
+	case "lxc":
+		if !lxcNICNameRe.MatchString(s.Name) {
+			return fmt.Errorf("invalid interface name %q", s.Name)
+		}
+		switch s.IP {
+		case "", "dhcp", "manual":
+			if s.GW != "" {
+				return fmt.Errorf("a gateway needs a static IP")
+			}
+		default:
+			ip, _, err := net.ParseCIDR(s.IP)
+			if err != nil || ip.To4() == nil {
+				return fmt.Errorf("invalid IP %q (dhcp, manual or IPv4 CIDR)", s.IP)
+			}
+			if s.GW != "" {
+				if _, err := parseIPv4(s.GW); err != nil {
+					return fmt.Errorf("invalid gateway %q", s.GW)
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("unknown VM type %q", vmType)
+	}
+	return nil
+}
+
+// buildNetString validates spec and returns the netN value for it. Options of
+// cur that PNAT does not edit (e.g. queues, mtu, ip6) are kept.
+func buildNetString(vmType, cur string, spec NICSpec) (string, error) {
+	if err := spec.validate(vmType); err != nil {
+		return "", err
+	}
+	managed := map[string]bool{"bridge": true, "tag": true, "firewall": true, "rate": true}
+	var head []string
+	rest := splitCommaKV(cur)
+	if vmType == "qemu" {
+		if len(rest) > 0 {
+			rest = rest[1:] // model=MAC
+		}
+		if spec.MAC != "" {
+			head = append(head, spec.Model+"="+spec.MAC)
+		} else {
+			head = append(head, spec.Model)
+		}
+	} else {
+		for _, k := range []string{"name", "hwaddr", "ip", "gw"} {
+			managed[k] = true
+		}
+		head = append(head, "name="+spec.Name)
+		if spec.MAC != "" {
+			head = append(head, "hwaddr="+spec.MAC)
+		}
+	}
+	head = append(head, "bridge="+spec.Bridge)
+	if vmType == "lxc" {
+		if spec.IP != "" {
+			head = append(head, "ip="+spec.IP)
+		}
+		if spec.GW != "" {
+			head = append(head, "gw="+spec.GW)
+		}
+	}
+	if spec.Tag > 0 {
+		head = append(head, fmt.Sprintf("tag=%d", spec.Tag))
+	}
+	if spec.Firewall {
+		head = append(head, "firewall=1")
+	}
+	if spec.Rate != "" {
+		head = append(head, "rate="+spec.Rate)
+	}
+	for _, p := range rest {
+		if k, _ := parseFirstKV(p); !managed[k] {
+			head = append(head, p)
+		}
+	}
+	return strings.Join(head, ","), nil
+}
+
+// checkNICSpec rejects a MAC or LXC interface name already used by another
+// NIC of the guest.
+func checkNICSpec(vmType string, cfg map[string]string, key string, spec NICSpec) error {
+	for k, v := range cfg {
+		if k == key || !netKeyRe.MatchString(k) {
+			continue
+		}
+		other := parseNICSpec(vmType, v)
+		if spec.MAC != "" && strings.EqualFold(other.MAC, spec.MAC) {
+			return fmt.Errorf("MAC %s is already used by %s", spec.MAC, k)
+		}
+		if vmType == "lxc" && other.Name == spec.Name {
+			return fmt.Errorf("interface name %s is already used by %s", spec.Name, k)
+		}
+	}
+	return nil
+}
+
+// maxGuestNICs is the number of netN keys Proxmox accepts per guest type.
+var maxGuestNICs = map[string]int{"qemu": 32, "lxc": 32}
+
+// nextNetKey returns the first netN key not used in the config of a guest of
+// vmType, or "" if all are used.
+func nextNetKey(vmType string, cfg map[string]string) string {
+	for i := 0; i < maxGuestNICs[vmType]; i++ {
+		if key := fmt.Sprintf("net%d", i); cfg[key] == "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// nextLXCNICName returns the first ethN name not used by a container NIC.
+func nextLXCNICName(cfg map[string]string) string {
+	used := map[string]bool{}
+	for k, v := range cfg {
+		if netKeyRe.MatchString(k) {
+			used[kvGet(splitCommaKV(v), "name")] = true
+		}
+	}
+	for i := 0; ; i++ {
+		if name := fmt.Sprintf("eth%d", i); !used[name] {
+			return name
+		}
+	}
+}
+
 func buildBridgeIPLists(cfg *Config, vms []VMView) []BridgeIPList {
 	// Only include bridges that PNAT manages (cfg.Bridges), since forwards are scoped to those subnets.
 	bridgeSet := map[string]struct{}{}
@@ -342,7 +601,7 @@ func buildBridgeIPLists(cfg *Config, vms []VMView) []BridgeIPList {
 			if nic.Bridge == "" {
 				continue
 			}
-			if _, ok := bridgeSet[nic.Bridge]; !ok {
+			if _, ok := bridgeSet[nic.Network]; !ok {
 				continue
 			}
 
@@ -350,7 +609,7 @@ func buildBridgeIPLists(cfg *Config, vms []VMView) []BridgeIPList {
 			if nic.LeaseIP != "" {
 				ips = append(ips, nic.LeaseIP)
 			}
-			for _, ip := range nic.IPs {
+			for _, ip := range append(nic.IPs, nic.AgentIPs...) {
 				ip = strings.TrimSpace(ip)
 				if ip == "" {
 					continue
@@ -364,14 +623,14 @@ func buildBridgeIPLists(cfg *Config, vms []VMView) []BridgeIPList {
 				if ip4 == nil {
 					continue
 				}
-				k := key{bridge: nic.Bridge, ip: ip4.String()}
+				k := key{bridge: nic.Network, ip: ip4.String()}
 				if seen[k] {
 					continue
 				}
 				seen[k] = true
 
 				label := fmt.Sprintf("%d %s (%s)", vm.VMID, vm.Name, nic.Key)
-				optsByBridge[nic.Bridge] = append(optsByBridge[nic.Bridge], BridgeIPOption{
+				optsByBridge[nic.Network] = append(optsByBridge[nic.Network], BridgeIPOption{
 					IP:    ip4.String(),
 					Label: label,
 				})
@@ -387,3 +646,152 @@ func buildBridgeIPLists(cfg *Config, vms []VMView) []BridgeIPList {
 	sort.Slice(out, func(i, j int) bool { return out[i].Bridge < out[j].Bridge })
 	return out
 }
+
+// reservedMACs maps every reserved MAC (normalized) to its reserved IP.
+func reservedMACs(cfg *Config) map[string]string {
+	out := map[string]string{}
+	for _, b := range cfg.Bridges {
+		if b.DHCP == nil {
+			continue
+		}
+		for _, h := range b.DHCP.Hosts {
+			out[normalizeMAC(h.MAC)] = h.IP
+		}
+	}
+	return out
+}
+
+// markReservedNICs flags NICs whose current lease is already pinned by a reservation.
+func markReservedNICs(cfg *Config, vms []VMView) {
+	reserved := reservedMACs(cfg)
+	for i := range vms {
+		for j := range vms[i].NICs {
+			nic := &vms[i].NICs[j]
+			if ip, ok := reserved[normalizeMAC(nic.MAC)]; ok && (nic.LeaseIP == "" || nic.LeaseIP == ip) {
+				nic.Reserved = true
+			}
+		}
+	}
+}
+
+func buildLeaseViews(cfg *Config, leases []Lease, vms []VMView) []LeaseView {
+	reserved := reservedMACs(cfg)
+
+	type nicRef struct {
+		vm  *VMView
+		key string
+	}
+	nicByMAC := map[string]nicRef{}
+	for i := range vms {
+		for _, nic := range vms[i].NICs {
+			if nic.MAC != "" {
+				nicByMAC[normalizeMAC(nic.MAC)] = nicRef{vm: &vms[i], key: nic.Key}
+			}
+		}
+	}
+
+	out := make([]LeaseView, 0, len(leases))
+	for _, l := range leases {
+		v := LeaseView{Lease: l, Remaining: l.ExpiresInText()}
+		if br := cfg.BridgeForIP(l.IP); br != nil {
+			v.Bridge = br.Name
+		}
+		mac := normalizeMAC(l.MAC)
+		if ip, ok := reserved[mac]; ok && ip == l.IP {
+			v.Reserved = true
+		}
+		if ref, ok := nicByMAC[mac]; ok {
+			v.VMID = ref.vm.VMID
+			v.VMName = ref.vm.Name
+			v.NICKey = ref.key
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// attachLeaseHistory attaches each client's history record to views. Leases
+// are recorded by the web server in the background, not here.
+func attachLeaseHistory(history *LeaseHistory, views []LeaseView) {
+	for i := range views {
+		if r, ok := history.Get(views[i].MAC, views[i].IsIPv6()); ok {
+			views[i].History = &r
+		}
+	}
+}
+
+// filterLeaseViews keeps only leases on bridge; an empty bridge keeps all.
+func filterLeaseViews(views []LeaseView, bridge string) []LeaseView {
+	if bridge == "" {
+		return views
+	}
+	out := make([]LeaseView, 0, len(views))
+	for _, v := range views {
+		if v.Bridge == bridge {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// dnsHostsConfig copies what buildDNSHostLines reads from cfg: the bridges
+// with their subnets, DNS settings and reservations. The caller must hold the
+// config lock.
+func dnsHostsConfig(cfg *Config) *Config {
+	out := &Config{}
+	for _, b := range cfg.Bridges {
+		c := BridgeConfig{Name: b.Name, Subnet: b.Subnet, GatewayIP: b.GatewayIP}
+		if b.DNS != nil {
+			dns := *b.DNS
+			c.DNS = &dns
+		}
+		if b.DHCP != nil {
+			c.DHCP = &DHCPConfig{Hosts: append([]DHCPHost(nil), b.DHCP.Hosts...)}
+		}
+		if b.IPv6 != nil {
+			ipv6 := *b.IPv6
+			c.IPv6 = &ipv6
+		}
+		out.Bridges = append(out.Bridges, c)
+	}
+	return out
+}
+
+// buildDNSHostLines returns addn-hosts lines ("ip fqdn name") for guests on
+// bridges that publish Proxmox VM names. The address comes from a reservation,
+// then the current lease, then a static LXC ip=.
+func buildDNSHostLines(cfg *Config, vms []VMView) []string {
+	reserved := reservedMACs(cfg)
+	seen := map[string]bool{}
+	var lines []string
+	for _, vm := range vms {
+		name := strings.ToLower(vm.Name)
+		if !hostnameRe.MatchString(name) {
+			continue
+		}
+		for _, nic := range vm.NICs {
+			br := cfg.FindBridge(nic.Network)
+			if br == nil || br.DNS == nil || !br.DNS.VMNames {
+				continue
+			}
+			ip := reserved[normalizeMAC(nic.MAC)]
+			if ip == "" {
+				ip = nic.LeaseIP
+			}
+			if ip == "" && len(nic.IPs) > 0 {
+				ip = strings.Split(nic.IPs[0], "/")[0]
+			}
+			if cfg.BridgeForIP(ip) != br {
+				continue
+			}
+			fqdn := name + "." + br.DNS.Domain
+			if seen[fqdn] {
+				continue
+			}
+			seen[fqdn] = true
+			lines = append(lines, fmt.Sprintf("%s %s %s", ip, fqdn, name))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}